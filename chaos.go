@@ -0,0 +1,62 @@
+package mockhttp
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithChaos makes the MockServer respond to the given fraction of the requests to mocked
+// endpoints, chosen randomly, with failResp instead of the scenario response.
+// Without failResp the requests fail with 500 Internal Server Error.
+//
+// The scenarios are still matched and counted, so their expectations are unchanged.
+// Use WithSeed to make the failing requests reproducible.
+func WithChaos(failureRate float64, failResp ...Responder) Option {
+	if len(failResp) == 0 {
+		failResp = []Responder{ResponseStatusCode(http.StatusInternalServerError)}
+	}
+
+	return func(ms *MockServer) {
+		ms.responseConfig.chaos = &chaos{rate: failureRate, responders: failResp}
+	}
+}
+
+// WithSeed defines the seed of the random choices of the MockServer, such as WithChaos.
+func WithSeed(seed int64) Option {
+	return func(ms *MockServer) {
+		ms.seed = &seed
+	}
+}
+
+// chaos randomly chooses the requests that receive a failure response.
+type chaos struct {
+	rate       float64
+	responders []Responder
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// seed initializes the random source, using the current time if seed is nil.
+func (c *chaos) seed(seed *int64) {
+	s := time.Now().UnixNano()
+	if seed != nil {
+		s = *seed
+	}
+
+	c.rng = rand.New(rand.NewSource(s)) //nolint:gosec // not security sensitive
+}
+
+// strikes reports whether the next request must fail.
+func (c *chaos) strikes() bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rng.Float64() < c.rate
+}
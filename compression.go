@@ -0,0 +1,185 @@
+package mockhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// bufferedResponseWriter holds a handler response so it can be transformed before being sent.
+// Once the handler flushes, the response is streamed to w as is.
+type bufferedResponseWriter struct {
+	w          http.ResponseWriter
+	headers    http.Header
+	body       bytes.Buffer
+	statusCode int
+	streaming  bool
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.headers
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.streaming {
+		return b.w.Write(p)
+	}
+
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if b.streaming {
+		b.w.WriteHeader(statusCode)
+		return
+	}
+
+	if b.statusCode == 0 {
+		b.statusCode = statusCode
+	}
+}
+
+// Flush sends the response held so far and streams the rest of it, uncompressed.
+func (b *bufferedResponseWriter) Flush() {
+	if !b.streaming {
+		b.streaming = true
+		b.send(b.body.Bytes())
+	}
+
+	http.NewResponseController(b.w).Flush() //nolint:errcheck // the client may be gone
+}
+
+// send writes the status code and the body held.
+func (b *bufferedResponseWriter) send(body []byte) {
+	if b.statusCode > 0 {
+		b.w.WriteHeader(b.statusCode)
+	}
+
+	if len(body) > 0 {
+		b.w.Write(body) //nolint:errcheck // test helper
+	}
+}
+
+// autoGzip is a middleware that gzips response bodies for clients accepting it,
+// unless the response already defines a Content-Encoding or the handler flushes it,
+// as streams do.
+func autoGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsEncoding(r, "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedResponseWriter{w: w, headers: w.Header()}
+		next.ServeHTTP(bw, r)
+
+		if bw.streaming {
+			return
+		}
+
+		body := bw.body.Bytes()
+		if len(body) > 0 && bw.headers.Get("Content-Encoding") == "" {
+			var compressed bytes.Buffer
+
+			gw := gzip.NewWriter(&compressed)
+			gw.Write(body) //nolint:errcheck // writes to memory
+			gw.Close()     //nolint:errcheck // writes to memory
+
+			body = compressed.Bytes()
+
+			bw.headers.Set("Content-Encoding", "gzip")
+			bw.headers.Add("Vary", "Accept-Encoding")
+			bw.headers.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+
+		bw.send(body)
+	})
+}
+
+// acceptsEncoding reports whether the request Accept-Encoding lists the encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		if strings.EqualFold(name, encoding) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressors create the writers of the encodings supported by Scenario.WithCompression.
+var compressors = map[string]func(w io.Writer) io.WriteCloser{
+	"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	"br":      func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+}
+
+// compressedResponse is a Responder that compresses the body built by the previous
+// Responders with the encoding negotiated with the client, unless it already has a Content-Encoding.
+func compressedResponse(encodings []string) Responder {
+	return func(w http.ResponseWriter) {
+		mw, ok := w.(*memoryResponseWriter)
+		if !ok || len(mw.body) == 0 || mw.headers.Get("Content-Encoding") != "" {
+			return
+		}
+
+		mw.headers.Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(mw.request, encodings)
+		if encoding == "" {
+			return
+		}
+
+		var compressed bytes.Buffer
+
+		cw := compressors[encoding](&compressed)
+		cw.Write(mw.body) //nolint:errcheck // writes to memory
+		cw.Close()        //nolint:errcheck // writes to memory
+
+		mw.body = compressed.Bytes()
+		mw.headers.Set("Content-Encoding", encoding)
+	}
+}
+
+// negotiateEncoding returns the encoding with the highest Accept-Encoding quality value,
+// preferring the first in encodings on ties, or an empty string if none is accepted.
+func negotiateEncoding(r *http.Request, encodings []string) string {
+	qualities := make(map[string]float64)
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		qualities[strings.ToLower(name)] = q
+	}
+
+	var best string
+	var bestQ float64
+	for _, encoding := range encodings {
+		q, found := qualities[encoding]
+		if !found {
+			q = qualities["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
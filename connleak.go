@@ -0,0 +1,189 @@
+package mockhttp
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WithLeakCheck makes the MockServer track the connections it accepts and report, once the
+// test ends and the server is torn down, those still open, such as hijacked connections that
+// were not closed. Each of them holds the goroutines serving or using it.
+//
+// Only the connections of this MockServer are tracked, so parallel tests
+// and other servers do not affect the report.
+func WithLeakCheck() Option {
+	return func(ms *MockServer) {
+		ms.leakCheck = true
+	}
+}
+
+// leakCheckTimeout is how long connections have to be closed after Teardown.
+const leakCheckTimeout = time.Second
+
+// trackingListener is a net.Listener that records the connections
+// it accepts until they are closed.
+type trackingListener struct {
+	net.Listener
+
+	mu        sync.Mutex
+	conns     map[*trackedConn]struct{}
+	abandoned []string
+}
+
+func newTrackingListener(l net.Listener) *trackingListener {
+	return &trackingListener{Listener: l, conns: make(map[*trackedConn]struct{})}
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &trackedConn{Conn: conn, listener: l}
+
+	l.mu.Lock()
+	l.conns[tracked] = struct{}{}
+	l.mu.Unlock()
+
+	return tracked, nil
+}
+
+// abandonedBodies describes the responses whose body the client closed before receiving it
+// fully, and those still being written because the client stops reading them.
+func (l *trackingListener) abandonedBodies() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	abandoned := append([]string(nil), l.abandoned...)
+	for conn := range l.conns {
+		conn.mu.Lock()
+		if conn.writing {
+			abandoned = append(abandoned, "response body of "+conn.request+" was not read")
+		}
+		conn.mu.Unlock()
+	}
+
+	sort.Strings(abandoned)
+
+	return abandoned
+}
+
+// closeStalled closes the connections whose response the client stopped reading,
+// since the server waits for its responses to be written on Teardown.
+func (l *trackingListener) closeStalled() {
+	l.mu.Lock()
+	var stalled []*trackedConn
+	for conn := range l.conns {
+		conn.mu.Lock()
+		if conn.writing {
+			// the write error is not a body closed by the client.
+			conn.request = ""
+			stalled = append(stalled, conn)
+		}
+		conn.mu.Unlock()
+	}
+	l.mu.Unlock()
+
+	for _, conn := range stalled {
+		_ = conn.Close()
+	}
+}
+
+// open returns the remote address of the connections not closed yet.
+func (l *trackingListener) open() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addrs := make([]string, 0, len(l.conns))
+	for conn := range l.conns {
+		addrs = append(addrs, conn.RemoteAddr().String())
+	}
+
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+// trackedConn is a connection accepted by a trackingListener.
+//
+// It also follows the response being written, to tell when the client abandoned its body.
+type trackedConn struct {
+	net.Conn
+	listener *trackingListener
+	once     sync.Once
+
+	mu      sync.Mutex
+	request string
+	writing bool
+}
+
+// trackedConnKey is the request context key of the trackedConn serving the request.
+type trackedConnKey struct{}
+
+// serve records that the connection is serving the request, e.g. "GET /books?page=2".
+func (c *trackedConn) serve(request string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.request = request
+}
+
+// Write records when the client stops receiving the response: a write
+// fails if it closed the connection and blocks if it does not read.
+func (c *trackedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.writing = true
+	c.mu.Unlock()
+
+	n, err := c.Conn.Write(p)
+
+	c.mu.Lock()
+	request := c.request
+	c.writing = false
+	if err != nil {
+		c.request = ""
+	}
+	c.mu.Unlock()
+
+	if err != nil && request != "" {
+		c.listener.mu.Lock()
+		c.listener.abandoned = append(c.listener.abandoned, "response body of "+request+" was closed before being fully read")
+		c.listener.mu.Unlock()
+	}
+
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.listener.mu.Lock()
+		delete(c.listener.conns, c)
+		c.listener.mu.Unlock()
+	})
+
+	return c.Conn.Close()
+}
+
+// reportLeakedConnections reports the connections still open
+// after Teardown, waiting up to leakCheckTimeout for them to be closed.
+func (ms *MockServer) reportLeakedConnections(t testing.TB) {
+	t.Helper()
+
+	const interval = 10 * time.Millisecond
+
+	deadline := time.Now().Add(leakCheckTimeout)
+
+	leaked := ms.tracked.open()
+	for len(leaked) > 0 && time.Now().Before(deadline) {
+		time.Sleep(interval)
+		leaked = ms.tracked.open()
+	}
+
+	for _, addr := range leaked {
+		t.Errorf("mock server connection from %s is still open after teardown", addr)
+	}
+}
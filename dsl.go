@@ -0,0 +1,92 @@
+package mockhttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Interaction is a fluent builder for a Scenario, composing its matchers
+// and responders in a single chain.
+type Interaction struct {
+	scenario *Scenario
+}
+
+// Expect creates a mock for a request with the given HTTP method and path pattern,
+// to be configured fluently.
+func (ms *MockServer) Expect(method, pattern string) *Interaction {
+	return &Interaction{scenario: ms.registerEndpoint(strings.ToUpper(method), pattern)}
+}
+
+// With adds matchers to the interaction.
+func (i *Interaction) With(matchers ...Matcher) *Interaction {
+	i.scenario.matchers = append(i.scenario.matchers, matchers...)
+	return i
+}
+
+// WithJSONBody expects the request body to be JSON equivalent to jsonBody.
+func (i *Interaction) WithJSONBody(jsonBody string) *Interaction {
+	return i.With(MatchJSONBody(jsonBody))
+}
+
+// WithHeader expects the request to have the header with the given values.
+func (i *Interaction) WithHeader(name string, values ...string) *Interaction {
+	return i.With(MatchHeader(http.Header{http.CanonicalHeaderKey(name): values}))
+}
+
+// WithQueryParams expects the request query parameters to be exactly qp.
+func (i *Interaction) WithQueryParams(qp url.Values) *Interaction {
+	return i.With(MatchQueryParams(qp))
+}
+
+// Times sets how many requests it is expected to be received by this interaction.
+func (i *Interaction) Times(n int) *Interaction {
+	i.scenario.Times(n)
+	return i
+}
+
+// Respond starts the definition of the interaction response.
+func (i *Interaction) Respond() *InteractionResponse {
+	return &InteractionResponse{scenario: i.scenario}
+}
+
+// Scenario returns the underlying Scenario.
+func (i *Interaction) Scenario() *Scenario {
+	return i.scenario
+}
+
+// InteractionResponse is a fluent builder for the response of an Interaction.
+type InteractionResponse struct {
+	scenario *Scenario
+}
+
+// With adds responders to the response.
+func (ir *InteractionResponse) With(builders ...Responder) *InteractionResponse {
+	ir.scenario.builders = append(ir.scenario.builders, builders...)
+	return ir
+}
+
+// Status defines the response status code.
+func (ir *InteractionResponse) Status(code int) *InteractionResponse {
+	return ir.With(ResponseStatusCode(code))
+}
+
+// Header adds a response header with the given values.
+func (ir *InteractionResponse) Header(name string, values ...string) *InteractionResponse {
+	return ir.With(ResponseHeaders(http.Header{name: values}))
+}
+
+// JSON defines the response body as a JSON string.
+func (ir *InteractionResponse) JSON(jsonStr string) *InteractionResponse {
+	return ir.With(JSONResponseBody(jsonStr))
+}
+
+// Body defines the response body as a plain string.
+func (ir *InteractionResponse) Body(b string) *InteractionResponse {
+	return ir.With(StringResponseBody(b))
+}
+
+// Scenario returns the underlying Scenario.
+func (ir *InteractionResponse) Scenario() *Scenario {
+	return ir.scenario
+}
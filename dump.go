@@ -0,0 +1,100 @@
+package mockhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// WithDumpTo makes the MockServer write a wire-level dump of every request it receives
+// and of the response sent, in arrival order, to w.
+func WithDumpTo(w io.Writer) Option {
+	return func(ms *MockServer) {
+		ms.dumpTo = w
+	}
+}
+
+// dumpResponseWriter captures the response sent to the client for dumping.
+type dumpResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	reason     string
+	body       bytes.Buffer
+}
+
+func (d *dumpResponseWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
+
+func (d *dumpResponseWriter) WriteHeader(statusCode int) {
+	if d.statusCode == 0 {
+		d.statusCode = statusCode
+	}
+
+	d.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (d *dumpResponseWriter) recordRaw(status int, reason string, body []byte) {
+	if d.statusCode == 0 {
+		d.statusCode = status
+		d.reason = reason
+		d.body.Write(body)
+	}
+}
+
+func (d *dumpResponseWriter) Write(b []byte) (int, error) {
+	if d.statusCode == 0 {
+		d.statusCode = http.StatusOK
+	}
+
+	d.body.Write(b)
+
+	return d.ResponseWriter.Write(b)
+}
+
+// dump writes the request, with its recorded body, and the captured response to ms.dumpTo.
+//
+// Callers must hold ms.mu.
+func (ms *MockServer) dump(r *http.Request, body []byte, d *dumpResponseWriter) {
+	request := r.Clone(r.Context())
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	requestDump, err := httputil.DumpRequest(request, true)
+	if err != nil {
+		ms.T.Errorf("failed to dump request: %s", err.Error())
+		return
+	}
+
+	statusCode := d.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	var status string
+	if d.reason != "" {
+		status = fmt.Sprintf("%d %s", statusCode, d.reason)
+	}
+
+	response := &http.Response{
+		Status:        status,
+		StatusCode:    statusCode,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Header:        d.Header(),
+		Body:          io.NopCloser(bytes.NewReader(d.body.Bytes())),
+		ContentLength: int64(d.body.Len()),
+		Request:       request,
+	}
+
+	responseDump, err := httputil.DumpResponse(response, true)
+	if err != nil {
+		ms.T.Errorf("failed to dump response: %s", err.Error())
+		return
+	}
+
+	ms.dumpTo.Write(requestDump)  //nolint:errcheck // test helper
+	ms.dumpTo.Write(responseDump) //nolint:errcheck // test helper
+}
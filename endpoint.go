@@ -1,7 +1,20 @@
 package mockhttp
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 )
@@ -9,9 +22,27 @@ import (
 // Scenario is a mock case for a specific endpoint.
 type Scenario struct {
 	executionCount int64
+	mismatchCount  int64
+	inFlight       int64
+	maxInFlight    int64
+
 	times          int
+	expireAfter    int
 	builders       []Responder
+	respondFunc    RespondFunc
+	exactResponses [][]Responder
 	matchers       []Matcher
+	statusByCall   func(n int) int
+	compression    []string
+	meta           map[string]any
+	endpoint       string
+	owner          *Endpoint
+
+	mu            sync.Mutex
+	callsByTest   map[string]int
+	matchFailures []string
+	orderedBodies []string
+	bodies        [][]byte
 }
 
 func newScenario(matchers []Matcher) *Scenario {
@@ -25,11 +56,148 @@ func newScenario(matchers []Matcher) *Scenario {
 func (s *Scenario) Match(t *testing.T, r *http.Request) {
 	t.Helper()
 
-	atomic.AddInt64(&s.executionCount, 1)
+	s.match(t, r)
+}
+
+// match tries the matchers against the request, reports their failures to t and returns
+// the zero-based index of this call and how many matchers did not match.
+func (s *Scenario) match(t *testing.T, r *http.Request) (int, int) {
+	t.Helper()
+
+	call := atomic.AddInt64(&s.executionCount, 1) - 1
 
+	if s.orderedBodies != nil {
+		s.recordBody(t, r, int(call))
+	}
+
+	mismatches := s.evaluate(t, r)
+	if len(mismatches) == 0 {
+		return int(call), 0
+	}
+
+	atomic.AddInt64(&s.mismatchCount, 1)
+
+	var failures []string
+	for _, rec := range mismatches {
+		for _, failure := range rec.failures {
+			t.Errorf("%s", failure)
+		}
+
+		if rec.failed && len(rec.failures) == 0 {
+			t.Fail()
+		}
+
+		failures = append(failures, rec.report()...)
+	}
+
+	s.mu.Lock()
+	s.matchFailures = append(s.matchFailures, failures...)
+	s.mu.Unlock()
+
+	return int(call), len(mismatches)
+}
+
+// try runs the NewMatcher matchers against the request without failing the test
+// and returns those that did not match.
+func (s *Scenario) try(r *http.Request) []*matchRecorder {
+	detached := new(testing.T)
+
+	var mismatches []*matchRecorder
 	for _, m := range s.matchers {
-		m(t, r)
+		if !m.recordable() {
+			continue
+		}
+
+		if rec := m.evaluate(detached, r); rec.mismatched() {
+			mismatches = append(mismatches, rec)
+		}
+	}
+
+	return mismatches
+}
+
+// evaluate runs the matchers against the request, recording the reports
+// of their NewMatcher checks instead of failing t, and returns those that did not match.
+func (s *Scenario) evaluate(t *testing.T, r *http.Request) []*matchRecorder {
+	var mismatches []*matchRecorder
+	for _, m := range s.matchers {
+		if rec := m.evaluate(t, r); rec.mismatched() {
+			mismatches = append(mismatches, rec)
+		}
+	}
+
+	return mismatches
+}
+
+// recordBody stores the request body of the call for AssertBodiesInOrder.
+func (s *Scenario) recordBody(t *testing.T, r *http.Request, call int) {
+	t.Helper()
+
+	body, err := ReadBody(r)
+	if err != nil {
+		t.Errorf("failed to read request body: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.bodies) <= call {
+		s.bodies = append(s.bodies, nil)
+	}
+
+	s.bodies[call] = body
+}
+
+// AssertBodiesInOrder expects the Nth call of this Scenario to have the Nth body,
+// compared as JSON when both are valid JSON. It is verified by AssertExpectations.
+func (s *Scenario) AssertBodiesInOrder(bodies ...string) *Scenario {
+	s.orderedBodies = bodies
+	return s
+}
+
+// assertBodiesInOrder reports an error for each call whose body differs from the one
+// expected by AssertBodiesInOrder and returns whether the expectation was met.
+func (s *Scenario) assertBodiesInOrder(t testing.TB) bool {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	met := true
+	for i, expected := range s.orderedBodies {
+		if i >= len(s.bodies) {
+			t.Errorf("endpoint %s call %d was not received, expected body %s%s", s.endpoint, i+1, expected, s.describeMeta())
+			return false
+		}
+
+		if !equivalentBodies(s.bodies[i], []byte(expected)) {
+			met = false
+			t.Errorf(
+				"endpoint %s call %d has unexpected body: got %s, expected %s%s",
+				s.endpoint, i+1, s.bodies[i], expected, s.describeMeta(),
+			)
+		}
 	}
+
+	return met
+}
+
+// equivalentBodies reports whether the bodies are equal, or equivalent JSON when both are valid JSON.
+func equivalentBodies(actual, expected []byte) bool {
+	if !json.Valid(actual) || !json.Valid(expected) {
+		return bytes.Equal(actual, expected)
+	}
+
+	var a, e any
+	json.Unmarshal(actual, &a)   //nolint:errcheck // validated
+	json.Unmarshal(expected, &e) //nolint:errcheck // validated
+
+	return reflect.DeepEqual(a, e)
+}
+
+// AllMatched reports whether every matcher passed on every call of this Scenario.
+func (s *Scenario) AllMatched() bool {
+	return atomic.LoadInt64(&s.mismatchCount) == 0
 }
 
 // Times sets the how many requests it is expected to be received by this endpoint.
@@ -38,6 +206,51 @@ func (s *Scenario) Times(n int) *Scenario {
 	return s
 }
 
+// ResetCount zeroes how many times this Scenario was executed.
+//
+// If the endpoint already dispatched requests to this Scenario, the next requests are
+// served by this Scenario again, as many times as it was dispatched, before the response
+// plan resumes where it was. The other scenarios are not served again.
+func (s *Scenario) ResetCount() {
+	atomic.StoreInt64(&s.executionCount, 0)
+
+	s.mu.Lock()
+	s.callsByTest = nil
+	s.bodies = nil
+	s.mu.Unlock()
+
+	if s.owner != nil {
+		s.owner.replay(s)
+	}
+}
+
+// Expire makes the Scenario stop being dispatched after it served after requests, so the
+// next scenario of the endpoint takes over its remaining planned calls, serving them after
+// its own. The last scenario of the endpoint never expires.
+//
+// Times still defines the calls planned for this Scenario, but it is expected to be
+// called only after times, and the next scenario the remaining calls on top of its Times.
+func (s *Scenario) Expire(after int) *Scenario {
+	s.expireAfter = after
+	return s
+}
+
+// expectedTimes returns how many calls the Scenario is expected to receive.
+func (s *Scenario) expectedTimes() int {
+	if s.owner == nil {
+		return s.times
+	}
+
+	planned := s.owner.plannedTimes()
+	for i, scenario := range s.owner.scenarios {
+		if scenario == s {
+			return planned[i]
+		}
+	}
+
+	return s.times
+}
+
 // TimesCalled return how many times this Scenario was executed.
 func (s *Scenario) TimesCalled() int {
 	return int(atomic.LoadInt64(&s.executionCount))
@@ -49,30 +262,280 @@ func (s *Scenario) Respond(builders ...Responder) *Scenario {
 	return s
 }
 
-func (s *Scenario) respondTo(w http.ResponseWriter) {
-	mw := newMemoryResponseWriter()
+// RespondJSON set up the response with the status code and the JSON body.
+func (s *Scenario) RespondJSON(status int, body string) *Scenario {
+	return s.Respond(ResponseStatusCode(status), JSONResponseBody(body))
+}
+
+// RespondFunc computes the whole response to a request. A returned error
+// fails the test and the request gets a 500 Internal Server Error instead.
+type RespondFunc func(r *http.Request) (status int, headers http.Header, body []byte, err error)
+
+// RespondFunc sets fn to compute the response, replacing the Responders.
+// A zero status responds with 200 OK. It cannot be combined with RespondExactly.
+func (s *Scenario) RespondFunc(fn RespondFunc) *Scenario {
+	if s.exactResponses != nil {
+		panic("mockhttp: RespondFunc cannot be combined with RespondExactly")
+	}
+
+	s.respondFunc = fn
+	return s
+}
+
+// ExpectJSON adds a matcher that decodes the request body as a JSON object and runs
+// the predicate on it, failing the test with the returned error.
+func (s *Scenario) ExpectJSON(predicate func(decoded map[string]any) error) *Scenario {
+	s.matchers = append(s.matchers, newCallMatcher("ExpectJSON", []any{predicate}, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		var decoded map[string]any
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			t.Errorf("endpoint %s received a body that is not a json object: %s", s.endpoint, err.Error())
+			return
+		}
+
+		if err := predicate(decoded); err != nil {
+			t.Errorf("endpoint %s received an unexpected json body: %s", s.endpoint, err.Error())
+		}
+	}))
+
+	return s
+}
+
+// RespondExactly serves each set of Responders once, in order, and expects as many calls.
+// Any extra call fails the test and is answered with 500 Internal Server Error.
+// It cannot be combined with RespondFunc.
+func (s *Scenario) RespondExactly(resps ...[]Responder) *Scenario {
+	if s.respondFunc != nil {
+		panic("mockhttp: RespondExactly cannot be combined with RespondFunc")
+	}
+
+	s.exactResponses = resps
+	s.times = len(resps)
+	return s
+}
+
+// countTestCall records a call made by the test identified by id.
+func (s *Scenario) countTestCall(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.callsByTest == nil {
+		s.callsByTest = make(map[string]int)
+	}
+
+	s.callsByTest[id]++
+}
+
+// testCalls returns how many times each test identifier called this Scenario.
+func (s *Scenario) testCalls() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := make(map[string]int, len(s.callsByTest))
+	for id, n := range s.callsByTest {
+		calls[id] = n
+	}
+
+	return calls
+}
+
+// MaxConcurrentCalls returns the highest number of simultaneous in-flight calls to this Scenario.
+func (s *Scenario) MaxConcurrentCalls() int {
+	return int(atomic.LoadInt64(&s.maxInFlight))
+}
+
+// enter marks the start of a call and updates the concurrency high-water mark.
+func (s *Scenario) enter() {
+	storeMax(&s.maxInFlight, atomic.AddInt64(&s.inFlight, 1))
+}
+
+// storeMax atomically stores v in addr if it is greater than the current value.
+func storeMax(addr *int64, v int64) {
+	for {
+		current := atomic.LoadInt64(addr)
+		if v <= current || atomic.CompareAndSwapInt64(addr, current, v) {
+			return
+		}
+	}
+}
+
+// leave marks the end of a call.
+func (s *Scenario) leave() {
+	atomic.AddInt64(&s.inFlight, -1)
+}
+
+// StatusByCall computes the response status code from the zero-based call index,
+// overriding any status defined by the Responders.
+func (s *Scenario) StatusByCall(fn func(n int) int) *Scenario {
+	s.statusByCall = fn
+	return s
+}
+
+// Meta attaches metadata to the Scenario, such as its owner or ticket, which is listed
+// in Expectations and in the failures reported about the Scenario.
+func (s *Scenario) Meta(key string, value any) *Scenario {
+	if s.meta == nil {
+		s.meta = make(map[string]any)
+	}
+
+	s.meta[key] = value
+	return s
+}
+
+// describeMeta formats the Scenario metadata, sorted by key, to be appended
+// to failure messages, or returns an empty string if there is none.
+func (s *Scenario) describeMeta() string {
+	if len(s.meta) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(s.meta))
+	for key := range s.meta {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, s.meta[key])
+	}
+
+	return " [" + strings.Join(pairs, " ") + "]"
+}
+
+// WithCompression makes the Scenario compress its response body with the encoding the
+// client Accept-Encoding prefers among encodings, which may be "gzip", "deflate" and "br".
+// Ties are resolved by the order of encodings.
+func (s *Scenario) WithCompression(encodings ...string) *Scenario {
+	for _, encoding := range encodings {
+		if _, supported := compressors[encoding]; !supported {
+			panic("mockhttp: unsupported compression encoding " + encoding)
+		}
+	}
+
+	s.compression = encodings
+	return s
+}
+
+func (s *Scenario) respondTo(t *testing.T, w http.ResponseWriter, r *http.Request, call int, cfg responseConfig) int {
+	t.Helper()
+
+	builders := s.builders
+	if s.respondFunc != nil {
+		status, headers, body, err := s.respondFunc(r)
+		if err != nil {
+			t.Errorf("failed to respond to %s %s: %s", r.Method, r.URL.Path, err.Error())
+
+			return respondWith(t, w, r, []Responder{ResponseStatusCode(http.StatusInternalServerError)}, cfg)
+		}
+
+		builders = []Responder{ResponseHeaders(headers), StringResponseBody(string(body))}
+		if status > 0 {
+			builders = append(builders, ResponseStatusCode(status))
+		}
+	}
+
+	if s.exactResponses != nil {
+		if call >= len(s.exactResponses) {
+			t.Errorf("unexpected extra call to %s %s, expected exactly %d", r.Method, r.URL.Path, len(s.exactResponses))
+
+			return respondWith(t, w, r, []Responder{ResponseStatusCode(http.StatusInternalServerError)}, cfg)
+		}
+
+		builders = s.exactResponses[call]
+	}
+
+	if s.statusByCall != nil {
+		builders = append(builders[:len(builders):len(builders)], ResponseStatusCode(s.statusByCall(call)))
+	}
+
+	if s.compression != nil {
+		builders = append(builders[:len(builders):len(builders)], compressedResponse(s.compression))
+	}
 
-	for _, b := range s.builders {
+	return respondWith(t, w, r, builders, cfg)
+}
+
+// respondWith builds the response with the Responders and sends it,
+// returning the body bytes written.
+func respondWith(t testing.TB, w http.ResponseWriter, r *http.Request, builders []Responder, cfg responseConfig) int {
+	mw := newMemoryResponseWriter(t, r)
+
+	for _, b := range builders {
 		b(mw)
 	}
 
-	mw.flush(w)
+	return mw.flush(w, cfg)
 }
 
 // Endpoint defines an HTTP method and path that have
 // multiple mocked scenarios to produce responses.
 type Endpoint struct {
-	method string
-	path   string
+	method     string
+	path       string
+	pathRegexp *regexp.Regexp
 
 	requestCount int64
+	bytesServed  int64
 	scenarios    []*Scenario
+
+	isolated         bool
+	closestReport    bool
+	responseConfig   responseConfig
+	mu               sync.Mutex
+	isolatedRequests map[string]*int64
+	replays          map[*int64]*replay
+}
+
+// replay is a number of calls a scenario serves again, with ResetCount,
+// before the response plan resumes.
+type replay struct {
+	scenario int
+	calls    int64
+}
+
+// responseConfig holds the server-wide settings applied when flushing responses.
+type responseConfig struct {
+	prettyJSON bool
+	overflow   []Responder
+	chaos      *chaos
+	headers    http.Header
 }
 
 func newEndpoint(method, path string) *Endpoint {
 	return &Endpoint{method: method, path: path}
 }
 
+func newPatternEndpoint(method string, re *regexp.Regexp) *Endpoint {
+	return &Endpoint{method: method, path: re.String(), pathRegexp: re}
+}
+
+// plannedTimes returns how many calls of the response plan each scenario serves: a scenario
+// that expires hands its remaining calls over to the next one, which serves them after its own.
+func (e *Endpoint) plannedTimes() []int {
+	planned := make([]int, len(e.scenarios))
+
+	var handedOver int
+	for i, s := range e.scenarios {
+		planned[i] = s.times + handedOver
+		handedOver = 0
+
+		if s.expireAfter > 0 && s.expireAfter < planned[i] && i < len(e.scenarios)-1 {
+			handedOver = planned[i] - s.expireAfter
+			planned[i] = s.expireAfter
+		}
+	}
+
+	return planned
+}
+
 // Handler create an HTTP handler that executes each scenario in the order
 // they were defined. If a scenario defines a Times expectation, the scenario
 // is executed the number of times it's defined.
@@ -80,53 +543,396 @@ func (e *Endpoint) Handler(t *testing.T) http.HandlerFunc {
 	t.Helper()
 
 	var responsePlan []int
-	for index, s := range e.scenarios {
-		for i := 0; i < s.times; i++ {
+	for index, times := range e.plannedTimes() {
+		for i := 0; i < times; i++ {
 			responsePlan = append(responsePlan, index)
 		}
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		plan := atomic.LoadInt64(&e.requestCount)
-		if plan >= int64(len(responsePlan)) {
-			// if endpoint called more times than planned
-			// just use the last scenario for response
-			plan = int64(len(responsePlan) - 1)
+		requestCount := e.requestCounter(r)
+
+		// a replayed scenario does not advance the plan.
+		currentScenarioIndex, replayed := e.nextReplay(requestCount)
+
+		var overflowed bool
+		if !replayed {
+			// reserve the plan position upfront so concurrent
+			// requests are dispatched to distinct positions.
+			plan := atomic.AddInt64(requestCount, 1) - 1
+
+			overflowed = plan >= int64(len(responsePlan))
+			if overflowed {
+				// if endpoint called more times than planned
+				// just use the last scenario for response
+				plan = int64(len(responsePlan) - 1)
+			}
+
+			currentScenarioIndex = responsePlan[plan]
 		}
 
-		currentScenarioIndex := responsePlan[plan]
 		scenario := e.scenarios[currentScenarioIndex]
 
-		scenario.Match(t, r)
-		scenario.respondTo(w)
+		scenario.enter()
+		defer scenario.leave()
 
-		atomic.AddInt64(&e.requestCount, 1)
+		markHandled(r, e.Name(), currentScenarioIndex)
+
+		if e.isolated {
+			scenario.countTestCall(r.Header.Get(IsolationHeader))
+		}
+
+		call, mismatched := scenario.match(t, r)
+		if mismatched > 0 && e.closestReport {
+			e.reportClosest(t, r, currentScenarioIndex, mismatched)
+		}
+
+		var n int
+		switch {
+		case overflowed && e.responseConfig.overflow != nil:
+			n = respondWith(t, w, r, e.responseConfig.overflow, e.responseConfig)
+		case e.responseConfig.chaos.strikes():
+			n = respondWith(t, w, r, e.responseConfig.chaos.responders, e.responseConfig)
+		default:
+			n = scenario.respondTo(t, w, r, call, e.responseConfig)
+		}
+
+		atomic.AddInt64(&e.bytesServed, int64(n))
+	}
+}
+
+// reportClosest logs the scenario closest to a request that did not match the dispatched
+// one, and records it among the matcher failures: a scenario it fully matches, which suggests
+// the calls arrived out of order, or else the one with fewer failed matchers, with its diff.
+//
+// The other scenarios are tried on a copy of the request with their NewMatcher matchers only,
+// since plain matchers would fail the test.
+func (e *Endpoint) reportClosest(t testing.TB, r *http.Request, dispatched, dispatchedMismatched int) {
+	t.Helper()
+
+	if len(e.scenarios) == 1 {
+		return
+	}
+
+	body, err := ReadBody(r)
+	if err != nil {
+		return
+	}
+
+	closest, fewest := -1, 0
+	var diff []string
+	for i, s := range e.scenarios {
+		if i == dispatched {
+			continue
+		}
+
+		clone := r.Clone(r.Context())
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+
+		mismatches := s.try(clone)
+		if closest < 0 || len(mismatches) < fewest {
+			closest, fewest, diff = i, len(mismatches), nil
+			for _, rec := range mismatches {
+				diff = append(diff, rec.report()...)
+			}
+		}
+	}
+
+	scenario := e.scenarios[closest]
+
+	var report string
+	switch {
+	case fewest == 0:
+		report = fmt.Sprintf(
+			"endpoint %s request matches scenario %d%s, but call order dispatched it to scenario %d%s",
+			e.Name(), closest+1, scenario.describeMeta(), dispatched+1, e.scenarios[dispatched].describeMeta(),
+		)
+	case fewest < dispatchedMismatched:
+		report = fmt.Sprintf(
+			"endpoint %s request matches no scenario, the closest is scenario %d%s: %s",
+			e.Name(), closest+1, scenario.describeMeta(), strings.Join(diff, "; "),
+		)
+	default:
+		// the failures already reported are the closest diff.
+		return
+	}
+
+	t.Log(report)
+
+	e.scenarios[dispatched].mu.Lock()
+	e.scenarios[dispatched].matchFailures = append(e.scenarios[dispatched].matchFailures, report)
+	e.scenarios[dispatched].mu.Unlock()
+}
+
+// replay makes the endpoint serve the target scenario again, for every counter, as many
+// times as the response plan already dispatched it, before resuming the plan.
+func (e *Endpoint) replay(target *Scenario) {
+	planned := e.plannedTimes()
+
+	var start int64
+	index := 0
+	for i, s := range e.scenarios {
+		if s == target {
+			index = i
+			break
+		}
+
+		start += int64(planned[i])
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	counters := []*int64{&e.requestCount}
+	for _, counter := range e.isolatedRequests {
+		counters = append(counters, counter)
+	}
+
+	for _, counter := range counters {
+		dispatched := atomic.LoadInt64(counter) - start
+		if dispatched > int64(planned[index]) {
+			dispatched = int64(planned[index])
+		}
+
+		if dispatched <= 0 {
+			continue
+		}
+
+		if e.replays == nil {
+			e.replays = make(map[*int64]*replay)
+		}
+
+		e.replays[counter] = &replay{scenario: index, calls: dispatched}
+	}
+}
+
+// nextReplay returns the scenario to serve again for the counter, if any.
+func (e *Endpoint) nextReplay(counter *int64) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r, found := e.replays[counter]
+	if !found {
+		return 0, false
+	}
+
+	r.calls--
+	if r.calls == 0 {
+		delete(e.replays, counter)
 	}
+
+	return r.scenario, true
+}
+
+// requestCounter returns the counter used to select the scenario for the request.
+// With per-test isolation each test identifier has its own counter.
+func (e *Endpoint) requestCounter(r *http.Request) *int64 {
+	if !e.isolated {
+		return &e.requestCount
+	}
+
+	id := r.Header.Get(IsolationHeader)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.isolatedRequests == nil {
+		e.isolatedRequests = make(map[string]*int64)
+	}
+
+	counter, found := e.isolatedRequests[id]
+	if !found {
+		counter = new(int64)
+		e.isolatedRequests[id] = counter
+	}
+
+	return counter
 }
 
 // Name returns the endpoint name (method + path) that this Returner represents.
 func (e *Endpoint) Name() string {
+	if e.pathRegexp != nil {
+		return patternEndpointName(e.method, e.pathRegexp)
+	}
+
 	return endpointName(e.method, e.path)
 }
 
+// BytesServed returns the total size of the response bodies sent by this endpoint.
+func (e *Endpoint) BytesServed() int64 {
+	return atomic.LoadInt64(&e.bytesServed)
+}
+
 // AddScenario appends a scenario to the endpoint.
 func (e *Endpoint) AddScenario(s *Scenario) {
+	s.endpoint = e.Name()
+	s.owner = e
 	e.scenarios = append(e.scenarios, s)
 }
 
+// matchRecorder records the reports of the NewMatcher checks of a matcher,
+// so a scenario can try a request before failing the test.
+//
+// Cleanup, TempDir and Setenv are undone as soon as the matcher returns.
+type matchRecorder struct {
+	testing.TB
+	matcher  Matcher
+	failed   bool
+	skipped  bool
+	failures []string
+	cleanups []func()
+	// reported is set when a plain Matcher failed the test by itself.
+	reported bool
+}
+
+// mismatched reports whether the matcher did not match the request.
+func (m *matchRecorder) mismatched() bool {
+	return m.failed || m.reported
+}
+
+// report describes why the matcher did not match the request.
+func (m *matchRecorder) report() []string {
+	if m.reported || (m.failed && len(m.failures) == 0) {
+		return append(m.failures, fmt.Sprintf("matcher %s failed", describeMatcher(m.matcher)))
+	}
+
+	return m.failures
+}
+
+// runCleanups calls the functions registered with Cleanup, last added first called.
+func (m *matchRecorder) runCleanups() {
+	for i := len(m.cleanups) - 1; i >= 0; i-- {
+		m.cleanups[i]()
+	}
+}
+
+func (m *matchRecorder) Helper() {}
+
+func (m *matchRecorder) Error(args ...any) {
+	m.failed = true
+	m.failures = append(m.failures, fmt.Sprint(args...))
+}
+
+func (m *matchRecorder) Errorf(format string, args ...any) {
+	m.failed = true
+	m.failures = append(m.failures, fmt.Sprintf(format, args...))
+}
+
+func (m *matchRecorder) Fail() {
+	m.failed = true
+}
+
+func (m *matchRecorder) FailNow() {
+	m.failed = true
+	runtime.Goexit()
+}
+
+func (m *matchRecorder) Fatal(args ...any) {
+	m.Error(args...)
+	m.FailNow()
+}
+
+func (m *matchRecorder) Fatalf(format string, args ...any) {
+	m.Errorf(format, args...)
+	m.FailNow()
+}
+
+func (m *matchRecorder) Failed() bool {
+	return m.failed
+}
+
+func (m *matchRecorder) Skip(args ...any) {
+	m.Log(args...)
+	m.SkipNow()
+}
+
+func (m *matchRecorder) Skipf(format string, args ...any) {
+	m.Logf(format, args...)
+	m.SkipNow()
+}
+
+func (m *matchRecorder) SkipNow() {
+	m.skipped = true
+	runtime.Goexit()
+}
+
+func (m *matchRecorder) Skipped() bool {
+	return m.skipped
+}
+
+func (m *matchRecorder) Cleanup(f func()) {
+	m.cleanups = append(m.cleanups, f)
+}
+
+func (m *matchRecorder) TempDir() string {
+	dir, err := os.MkdirTemp("", "mockhttp-matcher")
+	if err != nil {
+		m.Fatalf("failed to create temporary directory: %s", err.Error())
+	}
+
+	m.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func (m *matchRecorder) Setenv(key, value string) {
+	previous, found := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		m.Fatalf("failed to set environment variable %s: %s", key, err.Error())
+	}
+
+	m.Cleanup(func() {
+		if found {
+			_ = os.Setenv(key, previous)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
 // memoryResponseWriter accumulates all response builders
 // mutations such that the order they are used in test does not matter.
 //
 // This is necessary because if ResponseStatusCode is used after JSONResponseBody, the
 // status will be fixed at 200 by the Write call to http.ResponseWriter.
 type memoryResponseWriter struct {
+	t          testing.TB
+	request    *http.Request
+	handler    http.Handler
 	headers    http.Header
 	body       []byte
 	statusCode int
+	reason     string
+	failed     bool
 }
 
-func newMemoryResponseWriter() *memoryResponseWriter {
-	return &memoryResponseWriter{headers: make(http.Header)}
+func newMemoryResponseWriter(t testing.TB, r *http.Request) *memoryResponseWriter {
+	return &memoryResponseWriter{t: t, request: r, headers: make(http.Header)}
+}
+
+// failResponse fails the test of the MockServer responding through w and makes the
+// response a 500 Internal Server Error, which the other Responders cannot change.
+func failResponse(w http.ResponseWriter, format string, args ...any) {
+	mw, ok := w.(*memoryResponseWriter)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	mw.t.Errorf(format, args...)
+	mw.statusCode = http.StatusInternalServerError
+	mw.failed = true
+}
+
+// requestOf returns the request being responded by w,
+// or nil if w is not a memoryResponseWriter.
+func requestOf(w http.ResponseWriter) *http.Request {
+	mw, ok := w.(*memoryResponseWriter)
+	if !ok {
+		return nil
+	}
+
+	return mw.request
 }
 
 func (m *memoryResponseWriter) Header() http.Header {
@@ -134,30 +940,183 @@ func (m *memoryResponseWriter) Header() http.Header {
 }
 
 func (m *memoryResponseWriter) Write(bytes []byte) (int, error) {
-	m.body = bytes
+	if !m.failed {
+		m.body = bytes
+	}
+
 	return len(bytes), nil
 }
 
 func (m *memoryResponseWriter) WriteHeader(statusCode int) {
-	m.statusCode = statusCode
+	if !m.failed {
+		m.statusCode = statusCode
+	}
 }
 
-func (m *memoryResponseWriter) flush(w http.ResponseWriter) {
+// flush copies the accumulated response to w and returns the number of body bytes written.
+func (m *memoryResponseWriter) flush(w http.ResponseWriter, cfg responseConfig) int {
+	for k, values := range cfg.headers {
+		if _, overridden := m.headers[k]; !overridden {
+			w.Header()[k] = append([]string(nil), values...)
+		}
+	}
+
 	for k, values := range m.headers {
 		for _, v := range values {
 			w.Header().Add(k, v)
 		}
 	}
 
+	if m.failed {
+		w.WriteHeader(http.StatusInternalServerError)
+		return 0
+	}
+
+	if m.handler != nil {
+		cw := &countingResponseWriter{ResponseWriter: w}
+		m.handler.ServeHTTP(cw, m.request)
+
+		return cw.written
+	}
+
+	body := m.body
+	if len(body) == 0 || !bodyAllowedForStatus(m.statusCode) {
+		body = nil
+	}
+
+	if cfg.prettyJSON && isJSONContentType(m.headers.Get("Content-Type")) {
+		body = indentJSON(body)
+	}
+
+	if m.reason != "" {
+		if n, ok := writeRawResponse(w, m.statusCode, m.reason, body); ok {
+			return n
+		}
+	}
+
 	if m.statusCode > 0 {
 		w.WriteHeader(m.statusCode)
 	}
 
-	if len(m.body) > 0 {
-		w.Write(m.body) //nolint:errcheck // test helper
+	if len(body) == 0 {
+		return 0
 	}
+
+	n, _ := w.Write(body)
+
+	return n
+}
+
+// writeRawResponse hijacks the connection to write a status line with a custom reason phrase,
+// closing the connection afterwards. It reports false, without writing anything, if the
+// connection cannot be hijacked, as happens with HTTP/2.
+func writeRawResponse(w http.ResponseWriter, status int, reason string, body []byte) (int, bool) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+
+	recordRawResponse(w, status, reason, body)
+
+	headers := w.Header().Clone()
+	headers.Set("Content-Length", strconv.Itoa(len(body)))
+	headers.Set("Connection", "close")
+
+	fmt.Fprintf(buf, "HTTP/1.1 %03d %s\r\n", status, reason)
+	headers.Write(buf) //nolint:errcheck // flushed below
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	if err := buf.Flush(); err != nil {
+		return 0, true
+	}
+
+	return len(body), true
+}
+
+// rawResponseRecorder is implemented by the response writers recording the response,
+// which is written around them when the connection is hijacked.
+type rawResponseRecorder interface {
+	recordRaw(status int, reason string, body []byte)
+}
+
+// recordRawResponse records a response written to the hijacked connection in every
+// rawResponseRecorder wrapping w.
+func recordRawResponse(w http.ResponseWriter, status int, reason string, body []byte) {
+	for w != nil {
+		if rec, ok := w.(rawResponseRecorder); ok {
+			rec.recordRaw(status, reason, body)
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+
+		w = u.Unwrap()
+	}
+}
+
+// isJSONContentType reports whether the media type is application/json or a +json suffix type.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// indentJSON returns the body re-indented, or unchanged if it is not valid JSON.
+func indentJSON(body []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return body
+	}
+
+	return indented.Bytes()
+}
+
+// countingResponseWriter counts the body bytes written through it.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+func (c *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.written += n
+
+	return n, err
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may carry a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+
+	return true
 }
 
 func endpointName(m, p string) string {
 	return m + " " + p
 }
+
+// patternEndpointName names the endpoint of a regular expression, prefixed with "~"
+// so it cannot collide with chi patterns, which start with "/".
+func patternEndpointName(m string, re *regexp.Regexp) string {
+	return m + " ~" + re.String()
+}
@@ -0,0 +1,167 @@
+package mockhttp
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Expectation is a serializable view of a Scenario registered in the MockServer.
+type Expectation struct {
+	Endpoint   string         `json:"endpoint"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	Times      int            `json:"times"`
+	Matchers   []string       `json:"matchers"`
+	Responders []string       `json:"responders"`
+	Meta       map[string]any `json:"meta,omitempty"`
+}
+
+// Expectations returns a snapshot of every scenario, ordered by endpoint name
+// and then by registration order.
+//
+// The built-in Matchers and Responders are described by the call that built them,
+// e.g. `MatchHeaderPresent("X-Request-Id")` or `ResponseStatusCode(200)`, and the other ones
+// by the name of the function that built them.
+func (ms *MockServer) Expectations() []Expectation {
+	var expectations []Expectation
+	for _, endpoint := range ms.sortedEndpoints() {
+		for _, scenario := range endpoint.scenarios {
+			matchers := make([]string, len(scenario.matchers))
+			for i, m := range scenario.matchers {
+				matchers[i] = describeMatcher(m)
+			}
+
+			responders := make([]string, len(scenario.builders))
+			for i, b := range scenario.builders {
+				responders[i] = describeResponder(b)
+			}
+
+			var meta map[string]any
+			if scenario.meta != nil {
+				meta = make(map[string]any, len(scenario.meta))
+				for key, value := range scenario.meta {
+					meta[key] = value
+				}
+			}
+
+			expectations = append(expectations, Expectation{
+				Endpoint:   endpoint.Name(),
+				Method:     endpoint.method,
+				Path:       endpoint.path,
+				Times:      scenario.times,
+				Matchers:   matchers,
+				Responders: responders,
+				Meta:       meta,
+			})
+		}
+	}
+
+	return expectations
+}
+
+// describeFunc returns the name of the function that created fn,
+// without the package path and closure suffixes.
+func describeFunc(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	parts := strings.Split(name, ".")
+	for len(parts) > 2 && isClosureSuffix(parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+	}
+
+	if parts[0] == "mockhttp" {
+		parts = parts[1:]
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// isClosureSuffix reports whether a function name part was generated
+// by the compiler for a closure, such as "func1" or "1".
+func isClosureSuffix(part string) bool {
+	part = strings.TrimPrefix(part, "func")
+
+	return part != "" && strings.Trim(part, "0123456789") == ""
+}
+
+// describeCall describes a call to the named function with its arguments.
+//
+// Functions are described by their name, types, pointers and keys by their type,
+// and the other arguments by their Go syntax.
+func describeCall(name string, args ...any) string {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		formatted[i] = describeArg(arg)
+	}
+
+	return name + "(" + strings.Join(formatted, ", ") + ")"
+}
+
+// keyArg is a describeCall argument holding a secret or key, described only by its type
+// so that it is not printed.
+type keyArg struct {
+	key any
+}
+
+// describeArg describes an argument of describeCall.
+func describeArg(arg any) string {
+	switch arg := arg.(type) {
+	case keyArg:
+		return fmt.Sprintf("%T", arg.key)
+	case string, []byte, []string, [][]byte:
+		return fmt.Sprintf("%q", arg)
+	case reflect.Type:
+		return arg.String()
+	case fmt.Stringer:
+		return arg.String()
+	}
+
+	switch reflect.ValueOf(arg).Kind() {
+	case reflect.Func:
+		return describeFunc(arg)
+	case reflect.Pointer:
+		return fmt.Sprintf("%T", arg)
+	}
+
+	return fmt.Sprintf("%#v", arg)
+}
+
+// exactArgs reports whether describeArg tells apart every value of args: functions,
+// pointers and keys are only described by their name or type.
+func exactArgs(args []any) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case keyArg:
+			return false
+		case reflect.Type, fmt.Stringer:
+			continue
+		}
+
+		switch reflect.ValueOf(arg).Kind() {
+		case reflect.Func, reflect.Pointer:
+			return false
+		}
+	}
+
+	return true
+}
+
+// stringArgs converts variadic string arguments for describeCall.
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	return args
+}
@@ -3,13 +3,15 @@ module github.com/caiorcferreira/mockhttp
 go 1.20
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/go-chi/chi/v5 v5.0.4
 	github.com/google/go-cmp v0.5.9
-	github.com/stretchr/testify v1.7.0
+	github.com/stretchr/testify v1.8.2
+	golang.org/x/sys v0.6.0
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,21 @@
+module github.com/caiorcferreira/mockhttp/grpcweb
+
+go 1.20
+
+require (
+	github.com/caiorcferreira/mockhttp v0.0.0
+	github.com/stretchr/testify v1.8.2
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-chi/chi/v5 v5.0.4 // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/caiorcferreira/mockhttp => ../
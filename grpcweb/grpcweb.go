@@ -0,0 +1,111 @@
+// Package grpcweb provides mockhttp matchers for gRPC-Web requests,
+// kept apart so the main package does not depend on protobuf.
+package grpcweb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/caiorcferreira/mockhttp"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	compressedFlag = 0x01
+	trailerFlag    = 0x80
+	prefixSize     = 5
+)
+
+// MatchGRPCWebMessage verifies that the first message framed in the gRPC-Web
+// request body is equal to expected, decompressing it when the frame is flagged as
+// compressed with the grpc-encoding of the request.
+func MatchGRPCWebMessage(expected proto.Message) mockhttp.Matcher {
+	description := fmt.Sprintf("grpcweb.MatchGRPCWebMessage(%T{%s})", expected, prototext.MarshalOptions{}.Format(expected))
+
+	return mockhttp.NewDescribedMatcher(description, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := mockhttp.ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		payload, err := firstMessage(r, body)
+		if err != nil {
+			t.Errorf("failed to read gRPC-Web message: %s", err.Error())
+			return
+		}
+
+		actual := expected.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(payload, actual); err != nil {
+			t.Errorf("gRPC-Web message is not a %T: %s", expected, err.Error())
+			return
+		}
+
+		if !proto.Equal(expected, actual) {
+			t.Errorf(
+				"unexpected gRPC-Web message: got {%s}, expected {%s}",
+				prototext.Format(actual),
+				prototext.Format(expected),
+			)
+		}
+	})
+}
+
+// firstMessage returns the payload of the first data frame of the body.
+func firstMessage(r *http.Request, body []byte) ([]byte, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web-text") {
+		decoded, err := base64.StdEncoding.DecodeString(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+
+		body = decoded
+	}
+
+	for len(body) >= prefixSize {
+		flag := body[0]
+		size := binary.BigEndian.Uint32(body[1:prefixSize])
+		if uint64(len(body)-prefixSize) < uint64(size) {
+			return nil, fmt.Errorf("frame of %d bytes is truncated", size)
+		}
+
+		payload := body[prefixSize : prefixSize+int(size)]
+		body = body[prefixSize+int(size):]
+
+		if flag&trailerFlag != 0 {
+			continue
+		}
+
+		if flag&compressedFlag == 0 {
+			return payload, nil
+		}
+
+		return decompress(r.Header.Get("Grpc-Encoding"), payload)
+	}
+
+	return nil, errors.New("body has no message frame")
+}
+
+// decompress decodes a compressed message payload.
+func decompress(encoding string, payload []byte) ([]byte, error) {
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported grpc-encoding %q", encoding)
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(gr)
+}
@@ -0,0 +1,87 @@
+package grpcweb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/caiorcferreira/mockhttp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func frame(flag byte, payload []byte) []byte {
+	prefix := make([]byte, prefixSize)
+	prefix[0] = flag
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(payload)))
+
+	return append(prefix, payload...)
+}
+
+func TestMatchGRPCWebMessage(t *testing.T) {
+	message, err := proto.Marshal(wrapperspb.String("Foundation"))
+	require.NoError(t, err)
+
+	other, err := proto.Marshal(wrapperspb.String("Dune"))
+	require.NoError(t, err)
+
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err = gw.Write(message)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	testCases := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		failed      bool
+	}{
+		{name: "plain", contentType: "application/grpc-web+proto", body: frame(0, message)},
+		{
+			name:        "compressed",
+			contentType: "application/grpc-web+proto",
+			encoding:    "gzip",
+			body:        frame(compressedFlag, compressed.Bytes()),
+		},
+		{
+			name:        "text",
+			contentType: "application/grpc-web-text",
+			body:        []byte(base64.StdEncoding.EncodeToString(frame(0, message))),
+		},
+		{name: "different", contentType: "application/grpc-web+proto", body: frame(0, other), failed: true},
+		{name: "truncated", contentType: "application/grpc-web+proto", body: frame(0, message)[:8], failed: true},
+		{name: "trailer only", contentType: "application/grpc-web+proto", body: frame(trailerFlag, nil), failed: true},
+	}
+
+	for _, tc := range testCases {
+		mockT := new(testing.T)
+
+		ms := mockhttp.NewMockServer()
+
+		ms.Post("/books.v1.Books/Create", MatchGRPCWebMessage(wrapperspb.String("Foundation"))).
+			Respond(mockhttp.ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+
+		request, err := http.NewRequest(http.MethodPost, ms.URL()+"/books.v1.Books/Create", bytes.NewReader(tc.body))
+		require.NoError(t, err)
+
+		request.Header.Set("Content-Type", tc.contentType)
+		if tc.encoding != "" {
+			request.Header.Set("Grpc-Encoding", tc.encoding)
+		}
+
+		_, err = http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equalf(t, tc.failed, mockT.Failed(), "case %s", tc.name)
+
+		ms.Teardown()
+	}
+}
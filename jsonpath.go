@@ -0,0 +1,112 @@
+package mockhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// MatchJSONPath verifies that the value at path in the JSON request body is equal to expected,
+// compared after encoding expected as JSON.
+//
+// The path supports the root "$", child names as ".name" or "['name']" and array indexes
+// as "[0]", e.g. "$.order.items[0].sku".
+func MatchJSONPath(path string, expected any) Matcher {
+	return newCallMatcher("MatchJSONPath", []any{path, expected}, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			t.Errorf("invalid json body: %s", err.Error())
+			return
+		}
+
+		actual, err := evalJSONPath(doc, path)
+		if err != nil {
+			t.Errorf("json path %s: %s", path, err.Error())
+			return
+		}
+
+		assert.Equal(t, normalizeJSON(t, expected), actual, "json path %s", path)
+	})
+}
+
+// evalJSONPath returns the value at path in the decoded JSON document.
+func evalJSONPath(doc any, path string) (any, error) {
+	rest, found := strings.CutPrefix(path, "$")
+	if !found {
+		return nil, errors.New("path must start with $")
+	}
+
+	current := doc
+	for rest != "" {
+		var key string
+		index := -1
+
+		switch {
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated name in %q", rest)
+			}
+
+			key, rest = rest[2:end], rest[end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in %q", rest)
+			}
+
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index %q", rest[1:end])
+			}
+
+			index, rest = i, rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+
+			key, rest = rest[1:end+1], rest[end+1:]
+			if key == "" {
+				return nil, errors.New("empty name in path")
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+
+		if index >= 0 {
+			array, ok := current.([]any)
+			if !ok || index >= len(array) {
+				return nil, fmt.Errorf("index %d not found", index)
+			}
+
+			current = array[index]
+			continue
+		}
+
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("field %q not found", key)
+		}
+
+		if current, ok = object[key]; !ok {
+			return nil, fmt.Errorf("field %q not found", key)
+		}
+	}
+
+	return current, nil
+}
@@ -0,0 +1,116 @@
+package mockhttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// register the hash functions used by JWT algorithms.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+var errJWTSignature = errors.New("invalid jwt signature")
+
+// parseJWT decodes the claims of a compact serialized JWT, verifying its signature with key.
+//
+// The key type selects the accepted algorithms: []byte for HS256/384/512, *rsa.PublicKey
+// for RS256/384/512 and *ecdsa.PublicKey for ES256/384/512. A nil key skips verification.
+func parseJWT(token string, key any) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:gomnd // header, payload and signature
+		return nil, fmt.Errorf("malformed jwt: expected 3 parts, got %d", len(parts))
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed jwt header: %w", err)
+	}
+
+	if key != nil {
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed jwt signature: %w", err)
+		}
+
+		if err := verifyJWT(header.Alg, parts[0]+"."+parts[1], signature, key); err != nil {
+			return nil, err
+		}
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed jwt claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(decoded, v)
+}
+
+func verifyJWT(alg, signingInput string, signature []byte, key any) error {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+	hash, found := hashes[strings.TrimLeft(alg, "HRSE")]
+	if !found || len(alg) != 5 { //nolint:gomnd // e.g. HS256
+		return fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case []byte:
+		if alg[:2] != "HS" {
+			return fmt.Errorf("jwt algorithm %q does not match a hmac secret", alg)
+		}
+
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(signingInput))
+
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errJWTSignature
+		}
+	case *rsa.PublicKey:
+		if alg[:2] != "RS" {
+			return fmt.Errorf("jwt algorithm %q does not match a rsa key", alg)
+		}
+
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errJWTSignature
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" {
+			return fmt.Errorf("jwt algorithm %q does not match a ecdsa key", alg)
+		}
+
+		half := len(signature) / 2 //nolint:gomnd // signature is r || s
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errJWTSignature
+		}
+	default:
+		return fmt.Errorf("unsupported jwt key type %T", key)
+	}
+
+	return nil
+}
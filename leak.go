@@ -0,0 +1,120 @@
+package mockhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// trackedBody wraps a response body to know whether the client read and closed it.
+type trackedBody struct {
+	io.ReadCloser
+	request string
+
+	mu     sync.Mutex
+	eof    bool
+	closed bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.mu.Lock()
+		b.eof = true
+		b.mu.Unlock()
+	}
+
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	return b.ReadCloser.Close()
+}
+
+// leaked describes how the body was abandoned, or returns an empty string if it was not.
+func (b *trackedBody) leaked() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !b.closed:
+		return "was not closed"
+	case !b.eof:
+		return "was closed before being fully read"
+	}
+
+	return ""
+}
+
+// trackingTransport wraps every response body returned by base in a trackedBody.
+type trackingTransport struct {
+	base http.RoundTripper
+	ms   *MockServer
+}
+
+func (tt *trackingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	response, err := tt.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &trackedBody{ReadCloser: response.Body, request: r.Method + " " + r.URL.RequestURI()}
+	response.Body = body
+
+	tt.ms.mu.Lock()
+	tt.ms.trackedBodies = append(tt.ms.trackedBodies, body)
+	tt.ms.mu.Unlock()
+
+	return response, nil
+}
+
+// Client returns an HTTP client configured to send requests to the MockServer.
+// It can be created before Start, unless the server uses WithTLS, since
+// the client must trust the certificate created on Start.
+//
+// With WithBodyLeakDetection, the response bodies it returns are tracked exactly: the ones
+// the test did not fully read and close are reported when the test ends.
+func (ms *MockServer) Client() *http.Client {
+	client := &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	if ms.tls {
+		if ms.server == nil {
+			panic("mockhttp: Client of a MockServer using WithTLS must be created after Start")
+		}
+
+		client = ms.server.Client()
+	}
+
+	if !ms.bodyLeakDetection {
+		return client
+	}
+
+	client.Transport = &trackingTransport{base: client.Transport, ms: ms}
+
+	return client
+}
+
+// reportLeakedBodies logs the response bodies that were abandoned by the client,
+// as tracked by Client and by the server connections.
+func (ms *MockServer) reportLeakedBodies(t *testing.T) {
+	t.Helper()
+
+	ms.mu.Lock()
+	for _, body := range ms.trackedBodies {
+		if reason := body.leaked(); reason != "" {
+			t.Logf("response body of %s %s", body.request, reason)
+		}
+	}
+	ms.mu.Unlock()
+
+	for _, abandoned := range ms.tracked.abandonedBodies() {
+		t.Log(abandoned)
+	}
+
+	ms.tracked.closeStalled()
+}
@@ -1,62 +1,1296 @@
 package mockhttp
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"  //nolint:gosec // checksums, not security
+	"crypto/sha1" //nolint:gosec // checksums, not security
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/google/go-cmp/cmp"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 )
 
-type Matcher2 interface {
+// RequestMatcher tells whether a request matches without reporting to a test,
+// and explains why it does not. Every Matcher is a RequestMatcher.
+type RequestMatcher interface {
 	Match(r *http.Request) bool
 	Diff(r *http.Request) string
 }
 
-type queryParamMatcher struct {
-	expected url.Values
+// Matcher2 is the former name of RequestMatcher.
+//
+// Deprecated: use RequestMatcher.
+type Matcher2 = RequestMatcher
+
+// Matcher verifies a request, reporting every mismatch to t.
+//
+// The failures of a Matcher built with NewMatcher, as the built-in ones, are recorded by the
+// scenario. The failure of any other Matcher is only noticed if t had not failed before.
+type Matcher func(t *testing.T, r *http.Request)
+
+// NewMatcher builds a Matcher from a check written against testing.TB, so that the scenario
+// can record its failures, for AllMatched and AssertNoMatcherFailures, and try a request with it
+// without failing the test.
+//
+// It is described in Expectations by the name of the function calling NewMatcher.
+func NewMatcher(check func(t testing.TB, r *http.Request)) Matcher {
+	return describedMatcher(matchDescription{text: describeFunc(check)}, check)
 }
 
-func (q queryParamMatcher) Match(r *http.Request) bool {
-	return cmp.Equal(q.expected, r.URL.Query())
+// NewDescribedMatcher builds a Matcher like NewMatcher, described by description
+// in Expectations, e.g. `MatchTenant("acme")`.
+//
+// The description must identify the check and its arguments: scenarios of an endpoint
+// whose matchers have the same descriptions are reported as ambiguous by Start.
+func NewDescribedMatcher(description string, check func(t testing.TB, r *http.Request)) Matcher {
+	return describedMatcher(matchDescription{text: description, exact: true}, check)
 }
 
-func (q queryParamMatcher) Diff(r *http.Request) string {
-	return cmp.Diff(q.expected, r.URL.Query())
+// newCallMatcher builds a Matcher described by describeCall(name, args...), for the built-in
+// matchers whose arguments may not be told apart by their description. It is only compared
+// by Start when the arguments are exactArgs.
+func newCallMatcher(name string, args []any, check func(t testing.TB, r *http.Request)) Matcher {
+	return describedMatcher(matchDescription{text: describeCall(name, args...), exact: exactArgs(args)}, check)
 }
 
-func MatchQueryParams2(qp url.Values) Matcher2 {
-	return queryParamMatcher{expected: qp}
+// matchDescription is the description of a Matcher built by describedMatcher.
+// It is exact when it identifies the check arguments, so Matchers can be compared by it.
+type matchDescription struct {
+	text  string
+	exact bool
 }
 
-type Matcher func(t *testing.T, r *http.Request)
+// describedMatcher builds the Matcher of NewMatcher and NewDescribedMatcher.
+//
+// It is not inlined so that every Matcher it builds shares the code identified by newMatcherCode.
+//
+//go:noinline
+func describedMatcher(description matchDescription, check func(t testing.TB, r *http.Request)) Matcher {
+	return func(t *testing.T, r *http.Request) {
+		t.Helper()
+		if d, ok := r.Context().Value(matchDescriptionKey{}).(*matchDescription); ok {
+			*d = description
+			return
+		}
+
+		if rec, ok := r.Context().Value(matchRecorderKey{}).(*matchRecorder); ok {
+			check(rec, r)
+			return
+		}
+
+		check(t, r)
+	}
+}
+
+// matchRecorderKey is the request context key of the matchRecorder used by NewMatcher checks.
+type matchRecorderKey struct{}
+
+// matchDescriptionKey is the request context key asking a NewMatcher Matcher for its description.
+type matchDescriptionKey struct{}
+
+// newMatcherCode identifies the function of every Matcher built with describedMatcher.
+var newMatcherCode = reflect.ValueOf(describedMatcher(matchDescription{}, nil)).Pointer()
+
+// describeMatcher returns the description of m, or the name of the function that built it.
+func describeMatcher(m Matcher) string {
+	return m.description().text
+}
+
+// description returns the description of m, which is only exact when m was built
+// with NewDescribedMatcher.
+func (m Matcher) description() matchDescription {
+	if !m.recordable() {
+		return matchDescription{text: describeFunc(m)}
+	}
+
+	var description matchDescription
+	m(new(testing.T), (&http.Request{}).WithContext(context.WithValue(context.Background(), matchDescriptionKey{}, &description)))
+
+	return description
+}
+
+// recordable reports whether m was built with NewMatcher, so its failures can be recorded
+// without failing the test.
+func (m Matcher) recordable() bool {
+	return reflect.ValueOf(m).Pointer() == newMatcherCode
+}
+
+// Match reports whether the request satisfies m.
+//
+// A Matcher not built with NewMatcher runs against a detached *testing.T, whose Cleanup
+// functions never run.
+func (m Matcher) Match(r *http.Request) bool {
+	return !m.evaluate(new(testing.T), r).mismatched()
+}
+
+// Diff describes why the request does not satisfy m, or is empty if it does.
+func (m Matcher) Diff(r *http.Request) string {
+	return strings.Join(m.evaluate(new(testing.T), r).report(), "\n")
+}
+
+// evaluate runs m against the request, recording the reports of its NewMatcher checks
+// instead of failing t.
+//
+// The matcher runs on its own goroutine, so FailNow stops it like in a test
+// without stopping the caller. A panic of the matcher is raised again on the caller.
+//
+// A plain Matcher fails t by itself. If t already failed, that would not tell whether
+// the matcher failed, so it runs on a detached T and its failure is reported to t.
+func (m Matcher) evaluate(t *testing.T, r *http.Request) *matchRecorder {
+	rec := &matchRecorder{TB: t, matcher: m}
+
+	ctx := r.Context()
+	*r = *r.WithContext(context.WithValue(ctx, matchRecorderKey{}, rec))
+	defer func() { *r = *r.WithContext(ctx) }()
+
+	target := t
+	if t.Failed() && !m.recordable() {
+		target = new(testing.T)
+	}
+
+	failed := target.Failed()
+
+	var recovered any
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer rec.runCleanups()
+		defer func() { recovered = recover() }()
+
+		m(target, r)
+	}()
+	<-done
+
+	if recovered != nil {
+		panic(recovered)
+	}
+
+	rec.reported = !failed && target.Failed()
+	if rec.reported && target != t {
+		t.Errorf("matcher %s failed", describeMatcher(m))
+	}
+
+	return rec
+}
+
+// AsMatcher adapts a RequestMatcher, such as a custom implementation,
+// to a Matcher reporting its Diff when the request does not match.
+func AsMatcher(m RequestMatcher) Matcher {
+	if matcher, ok := m.(Matcher); ok {
+		return matcher
+	}
+
+	return newCallMatcher("AsMatcher", []any{m}, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if !m.Match(r) {
+			t.Errorf("request does not match: %s", m.Diff(r))
+		}
+	})
+}
+
+// MatchQueryParams2 returns MatchQueryParams as a RequestMatcher.
+//
+// Deprecated: every Matcher is a RequestMatcher, use MatchQueryParams.
+func MatchQueryParams2(qp url.Values) RequestMatcher {
+	return MatchQueryParams(qp)
+}
 
 func MatchQueryParams(qp url.Values) Matcher {
-	return func(t *testing.T, r *http.Request) {
+	return NewDescribedMatcher(describeCall("MatchQueryParams", qp), func(t testing.TB, r *http.Request) {
 		t.Helper()
 		assert.Equal(t, qp, r.URL.Query())
+	})
+}
+
+// MatchQueryParam verifies that the query parameter has exactly the expected value,
+// ignoring every other parameter.
+func MatchQueryParam(key, value string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchQueryParam", key, value), matchQueryParamsSubset(url.Values{key: {value}}))
+}
+
+// MatchQueryParamsSubset verifies the listed query parameters, ignoring the unlisted ones
+// such as tracing parameters added by the client.
+func MatchQueryParamsSubset(qp url.Values) Matcher {
+	return NewDescribedMatcher(describeCall("MatchQueryParamsSubset", qp), matchQueryParamsSubset(qp))
+}
+
+// matchQueryParamsSubset checks the listed query parameters.
+func matchQueryParamsSubset(qp url.Values) func(t testing.TB, r *http.Request) {
+	return func(t testing.TB, r *http.Request) {
+		t.Helper()
+		query := r.URL.Query()
+		for k, v := range qp {
+			actual, found := query[k]
+			if !found {
+				t.Errorf("query parameter %s is missing, expected %q", k, v)
+				continue
+			}
+
+			assert.Equal(t, v, actual, "query parameter %s", k)
+		}
+	}
+}
+
+// MatchPath verifies that the request path is exactly the expected one,
+// regardless of the route pattern that dispatched it.
+func MatchPath(expected string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchPath", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if r.URL.Path != expected {
+			t.Errorf("unexpected path: got %s, expected %s", r.URL.Path, expected)
+		}
+	})
+}
+
+// MatchPathParams verifies that the chi URL parameters of the route, as in "/users/{id}",
+// have the expected values.
+func MatchPathParams(expected map[string]string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchPathParams", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		for key, value := range expected {
+			if actual := chi.URLParam(r, key); actual != value {
+				t.Errorf("unexpected path parameter %s: got %q, expected %q", key, actual, value)
+			}
+		}
+	})
+}
+
+// MatchALPN verifies that the request arrived over TLS with the
+// negotiated ALPN protocol, such as "h2" or "http/1.1".
+func MatchALPN(proto string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchALPN", proto), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if r.TLS == nil {
+			t.Errorf("request was not received over TLS, expected ALPN protocol %s", proto)
+			return
+		}
+
+		if r.TLS.NegotiatedProtocol != proto {
+			t.Errorf("unexpected ALPN protocol: got %q, expected %q", r.TLS.NegotiatedProtocol, proto)
+		}
+	})
+}
+
+// MatchHeaderCount verifies that the header occurs exactly n times in the request.
+func MatchHeaderCount(name string, n int) Matcher {
+	return NewDescribedMatcher(describeCall("MatchHeaderCount", name, n), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		values := r.Header.Values(name)
+		if len(values) != n {
+			t.Errorf("header %s occurs %d times, expected %d: %q", name, len(values), n, values)
+		}
+	})
+}
+
+// MatchHeaderPresent verifies that the request has the header, whatever its value.
+func MatchHeaderPresent(key string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchHeaderPresent", key), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if len(r.Header.Values(key)) == 0 {
+			t.Errorf("header %s is missing", key)
+		}
+	})
+}
+
+// MatchHeaderAbsent verifies that the request does not have the header.
+func MatchHeaderAbsent(key string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchHeaderAbsent", key), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if values := r.Header.Values(key); len(values) > 0 {
+			t.Errorf("header %s should be absent, got %q", key, values)
+		}
+	})
+}
+
+// MatchBasicAuth verifies that the request has basic auth credentials with the user and password.
+func MatchBasicAuth(user, pass string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchBasicAuth", user, pass), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			t.Errorf("request has no Authorization header, expected basic auth for user %q", user)
+			return
+		}
+
+		actualUser, actualPass, ok := r.BasicAuth()
+		if !ok {
+			t.Errorf("malformed basic auth Authorization header: %q", header)
+			return
+		}
+
+		if actualUser != user {
+			t.Errorf("unexpected basic auth user: got %q, expected %q", actualUser, user)
+		}
+
+		if actualPass != pass {
+			t.Errorf("unexpected basic auth password for user %q: got %q, expected %q", actualUser, actualPass, pass)
+		}
+	})
+}
+
+// MatchJWTClaim verifies that the request has a bearer JWT, signed with secretOrKey,
+// whose claim equals expected. A nil secretOrKey skips signature verification.
+//
+// Use a []byte secret for HS256/384/512, a *rsa.PublicKey for RS256/384/512
+// and a *ecdsa.PublicKey for ES256/384/512.
+func MatchJWTClaim(secretOrKey any, claim string, expected any) Matcher {
+	return newCallMatcher("MatchJWTClaim", []any{keyArg{secretOrKey}, claim, expected}, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		token, found := bearerToken(r)
+		if !found {
+			t.Errorf("request has no bearer token")
+			return
+		}
+
+		claims, err := parseJWT(token, secretOrKey)
+		if err != nil {
+			t.Errorf("failed to parse jwt: %s", err.Error())
+			return
+		}
+
+		actual, found := claims[claim]
+		if !found {
+			t.Errorf("jwt has no claim %q", claim)
+			return
+		}
+
+		assert.Equal(t, normalizeJSON(t, expected), actual, "jwt claim %q", claim)
+	})
+}
+
+// MatchJWTClaims verifies that the request has a bearer JWT, signed with secretOrKey,
+// containing every expected claim. Claims not listed are ignored.
+// A nil secretOrKey skips signature verification.
+//
+// The accepted key types are the same as MatchJWTClaim.
+func MatchJWTClaims(secretOrKey any, expected map[string]any) Matcher {
+	return newCallMatcher("MatchJWTClaims", []any{keyArg{secretOrKey}, expected}, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		token, found := bearerToken(r)
+		if !found {
+			t.Errorf("request has no bearer token")
+			return
+		}
+
+		claims, err := parseJWT(token, secretOrKey)
+		if err != nil {
+			t.Errorf("failed to parse jwt: %s", err.Error())
+			return
+		}
+
+		for claim, value := range expected {
+			actual, found := claims[claim]
+			if !found {
+				t.Errorf("jwt has no claim %q", claim)
+				continue
+			}
+
+			assert.Equal(t, normalizeJSON(t, value), actual, "jwt claim %q", claim)
+		}
+	})
+}
+
+// MatchBearerToken verifies that the request Authorization header carries exactly the bearer token.
+func MatchBearerToken(token string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchBearerToken", token), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		actual, found := bearerToken(r)
+		if !found {
+			t.Errorf("request has no bearer token, Authorization header is %q", r.Header.Get("Authorization"))
+			return
+		}
+
+		if actual != token {
+			t.Errorf("unexpected bearer token: got %q, expected %q", actual, token)
+		}
+	})
+}
+
+// bearerToken extracts the token of a bearer Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// normalizeJSON converts v to the representation produced by json.Unmarshal into an interface.
+func normalizeJSON(t testing.TB, v any) any {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Error(err.Error())
+		return v
 	}
+
+	var normalized any
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		t.Error(err.Error())
+		return v
+	}
+
+	return normalized
+}
+
+// MatchRawQuery verifies that the raw query string is exactly the expected one,
+// preserving parameter order and encoding.
+func MatchRawQuery(expected string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchRawQuery", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if r.URL.RawQuery != expected {
+			t.Errorf("unexpected raw query: got %q, expected %q", r.URL.RawQuery, expected)
+		}
+	})
+}
+
+// MatchContentTypeParam verifies that the request Content-Type has the parameter,
+// such as charset or the multipart boundary, with the expected value.
+func MatchContentTypeParam(param, expected string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchContentTypeParam", param, expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		actual := r.Header.Get("Content-Type")
+
+		_, params, err := mime.ParseMediaType(actual)
+		if err != nil {
+			t.Errorf("invalid content type %q: %s", actual, err.Error())
+			return
+		}
+
+		value, found := params[strings.ToLower(param)]
+		if !found {
+			t.Errorf("content type parameter %s is missing, parameters are %v", param, params)
+			return
+		}
+
+		if value != expected {
+			t.Errorf("unexpected content type parameter %s: got %q, expected %q, parameters are %v", param, value, expected, params)
+		}
+	})
+}
+
+// MatchTimeHeader verifies that the header is an HTTP-date, in any of the formats
+// accepted by http.ParseTime, within the given duration of the current time.
+func MatchTimeHeader(name string, within time.Duration) Matcher {
+	return NewDescribedMatcher(describeCall("MatchTimeHeader", name, within), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		value := r.Header.Get(name)
+
+		parsed, err := http.ParseTime(value)
+		if err != nil {
+			t.Errorf("header %s is not an HTTP-date: %q", name, value)
+			return
+		}
+
+		now := time.Now()
+		if parsed.Before(now.Add(-within)) || parsed.After(now.Add(within)) {
+			t.Errorf("header %s time %s is not within %s of %s", name, parsed, within, now.UTC())
+		}
+	})
+}
+
+// MatchContentType verifies the request media type, tolerating parameters such as charset
+// the client may add. Parameters given in expected, as in "text/plain; charset=utf-8",
+// must be present with the same value.
+func MatchContentType(expected string) Matcher {
+	expectedType, expectedParams, err := mime.ParseMediaType(expected)
+	if err != nil {
+		panic(fmt.Sprintf("mockhttp: invalid content type %q: %s", expected, err.Error()))
+	}
+
+	return NewDescribedMatcher(describeCall("MatchContentType", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		actual := r.Header.Get("Content-Type")
+
+		mediaType, params, err := mime.ParseMediaType(actual)
+		if err != nil {
+			t.Errorf("invalid content type %q, expected %q", actual, expected)
+			return
+		}
+
+		if mediaType != expectedType {
+			t.Errorf("unexpected content type: got %q, expected %q", mediaType, expectedType)
+			return
+		}
+
+		for param, value := range expectedParams {
+			if !strings.EqualFold(params[param], value) {
+				t.Errorf("unexpected content type parameter %s: got %q, expected %q", param, params[param], value)
+			}
+		}
+	})
+}
+
+// MatchContentTypeIn verifies that the request media type is one of types,
+// ignoring parameters such as charset.
+func MatchContentTypeIn(types ...string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchContentTypeIn", stringArgs(types)...), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		actual := r.Header.Get("Content-Type")
+
+		mediaType, _, err := mime.ParseMediaType(actual)
+		if err != nil {
+			t.Errorf("invalid content type %q, expected one of %q", actual, types)
+			return
+		}
+
+		for _, expected := range types {
+			if strings.EqualFold(mediaType, expected) {
+				return
+			}
+		}
+
+		t.Errorf("unexpected content type: got %q, expected one of %q", mediaType, types)
+	})
+}
+
+// MatchHost verifies the host targeted by the client, taken from the Host header
+// or, for absolute-form requests, the URL host. The port is ignored unless host has one.
+func MatchHost(host string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchHost", host), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		actual := r.Host
+		if actual == "" {
+			actual = r.URL.Host
+		}
+
+		compared := actual
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			if hostname, _, err := net.SplitHostPort(actual); err == nil {
+				compared = hostname
+			}
+		}
+
+		if !strings.EqualFold(compared, host) {
+			t.Errorf("unexpected host: got %q, expected %q", actual, host)
+		}
+	})
+}
+
+// MatchUserAgent verifies that the request User-Agent is exactly ua.
+// Use MatchUserAgentRegex when the version or platform varies.
+func MatchUserAgent(ua string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchUserAgent", ua), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if actual := r.UserAgent(); actual != ua {
+			t.Errorf("unexpected user agent: got %q, expected %q", actual, ua)
+		}
+	})
+}
+
+// MatchUserAgentRegex verifies that the request User-Agent matches the regular expression,
+// as in `^my-sdk/\d+\.\d+\.\d+ `.
+func MatchUserAgentRegex(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return NewDescribedMatcher(describeCall("MatchUserAgentRegex", pattern), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		matchValuesRegex(t, "header User-Agent", r.Header.Values("User-Agent"), re)
+	})
+}
+
+// MatchLocalPort verifies that the request was received on the given local port.
+func MatchLocalPort(port int) Matcher {
+	return NewDescribedMatcher(describeCall("MatchLocalPort", port), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+		if !ok {
+			t.Errorf("request local address is unknown, expected port %d", port)
+			return
+		}
+
+		if addr.Port != port {
+			t.Errorf("unexpected local port: got %d, expected %d", addr.Port, port)
+		}
+	})
+}
+
+// MatchRequestTrailer verifies that the client sent the trailer with the given value
+// after a chunked body.
+//
+// Trailers are only available once the body is consumed,
+// so the matcher reads the whole body before inspecting them.
+func MatchRequestTrailer(name, value string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchRequestTrailer", name, value), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if _, err := ReadBody(r); err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		actual := r.Trailer.Values(name)
+		if len(actual) == 0 {
+			t.Errorf("request has no trailer %s", name)
+			return
+		}
+
+		assert.Equal(t, []string{value}, actual, "trailer %s", name)
+	})
 }
 
 func MatchHeader(headers http.Header) Matcher {
-	return func(t *testing.T, r *http.Request) {
+	return NewDescribedMatcher(describeCall("MatchHeader", headers), func(t testing.TB, r *http.Request) {
 		t.Helper()
 		for k, v := range headers {
 			assert.Equal(t, v, r.Header[k])
 		}
+	})
+}
+
+// MatchField is an aspect of a request compared by MatchLikeRequest.
+type MatchField int
+
+const (
+	// MethodField compares the request method.
+	MethodField MatchField = iota
+	// PathField compares the URL path.
+	PathField
+	// QueryField compares the query parameters.
+	QueryField
+	// HeadersField compares the headers defined in the template.
+	HeadersField
+	// BodyField compares the body, as JSON if the template Content-Type is JSON.
+	BodyField
+)
+
+// MatchLikeRequest verifies that the request is like template in the given fields,
+// or in every field if none is given. The template body is read when the matcher is created.
+func MatchLikeRequest(template *http.Request, fields ...MatchField) Matcher {
+	if len(fields) == 0 {
+		fields = []MatchField{MethodField, PathField, QueryField, HeadersField, BodyField}
+	}
+
+	matchers := make([]Matcher, 0, len(fields))
+	for _, field := range fields {
+		switch field {
+		case MethodField:
+			matchers = append(matchers, matchMethod(template.Method))
+		case PathField:
+			matchers = append(matchers, MatchPath(template.URL.Path))
+		case QueryField:
+			matchers = append(matchers, MatchQueryParams(template.URL.Query()))
+		case HeadersField:
+			matchers = append(matchers, MatchHeader(template.Header))
+		case BodyField:
+			matchers = append(matchers, matchBodyLike(template))
+		}
+	}
+
+	return func(t *testing.T, r *http.Request) {
+		t.Helper()
+		for _, m := range matchers {
+			m(t, r)
+		}
+	}
+}
+
+// matchMethod verifies the request method.
+func matchMethod(expected string) Matcher {
+	return NewMatcher(func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if r.Method != expected {
+			t.Errorf("unexpected method: got %s, expected %s", r.Method, expected)
+		}
+	})
+}
+
+// matchBodyLike verifies that the request body equals the template body.
+func matchBodyLike(template *http.Request) Matcher {
+	var expected []byte
+	if template.Body != nil {
+		body, err := ReadBody(template)
+		if err != nil {
+			return NewMatcher(func(t testing.TB, r *http.Request) {
+				t.Helper()
+				t.Errorf("failed to read template body: %s", err.Error())
+			})
+		}
+
+		expected = body
+	}
+
+	if isJSONContentType(template.Header.Get("Content-Type")) {
+		return MatchJSONBody(string(expected))
+	}
+
+	return MatchBody(expected)
+}
+
+// MatchHeadersExactly verifies that the request has exactly the expected headers and values,
+// reporting missing, added and different headers. The ignore headers and the hop-by-hop
+// headers, such as Connection or Content-Length, are not compared.
+func MatchHeadersExactly(expected http.Header, ignore ...string) Matcher {
+	ignored := make(map[string]bool, len(ignore)+len(hopByHopHeaders))
+	for _, names := range [][]string{ignore, hopByHopHeaders} {
+		for _, name := range names {
+			ignored[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+
+	want := make(http.Header, len(expected))
+	for name, values := range expected {
+		if name = http.CanonicalHeaderKey(name); !ignored[name] {
+			want[name] = append(want[name], values...)
+		}
+	}
+
+	return NewDescribedMatcher(describeCall("MatchHeadersExactly", append([]any{expected}, stringArgs(ignore)...)...), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		var missing, added []string
+		for name, values := range want {
+			actual, found := r.Header[name]
+			if !found {
+				missing = append(missing, name)
+				continue
+			}
+
+			if !reflect.DeepEqual(values, actual) {
+				t.Errorf("header %s differs: got %q, expected %q", name, actual, values)
+			}
+		}
+
+		for name := range r.Header {
+			if _, found := want[name]; !found && !ignored[name] {
+				added = append(added, name)
+			}
+		}
+
+		sort.Strings(missing)
+		sort.Strings(added)
+
+		if len(missing) > 0 {
+			t.Errorf("missing headers: %s", strings.Join(missing, ", "))
+		}
+
+		if len(added) > 0 {
+			t.Errorf("unexpected headers: %s", strings.Join(added, ", "))
+		}
+	})
+}
+
+// hopByHopHeaders are the headers managed by the HTTP transport, ignored by MatchHeadersExactly.
+var hopByHopHeaders = []string{
+	"Host",
+	"Content-Length",
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Te",
+	"Trailer",
+	"Upgrade",
+}
+
+// MatchBodyRegex verifies that the request body matches the regular expression.
+func MatchBodyRegex(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return NewDescribedMatcher(describeCall("MatchBodyRegex", pattern), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		if !re.Match(body) {
+			t.Errorf("body %q does not match %s", body, re)
+		}
+	})
+}
+
+// MatchHeaderRegex verifies that the request has the header and every value matches the regular expression.
+func MatchHeaderRegex(key, pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return NewDescribedMatcher(describeCall("MatchHeaderRegex", key, pattern), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		matchValuesRegex(t, "header "+key, r.Header.Values(key), re)
+	})
+}
+
+// MatchQueryParamRegex verifies that the request has the query parameter and every value
+// matches the regular expression.
+func MatchQueryParamRegex(key, pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return NewDescribedMatcher(describeCall("MatchQueryParamRegex", key, pattern), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		matchValuesRegex(t, "query parameter "+key, r.URL.Query()[key], re)
+	})
+}
+
+// matchValuesRegex reports the values that do not match the regular expression, or their absence.
+func matchValuesRegex(t testing.TB, name string, values []string, re *regexp.Regexp) {
+	t.Helper()
+
+	if len(values) == 0 {
+		t.Errorf("%s is missing, expected to match %s", name, re)
+		return
+	}
+
+	for _, v := range values {
+		if !re.MatchString(v) {
+			t.Errorf("%s value %q does not match %s", name, v, re)
+		}
+	}
+}
+
+// MatchFormBody verifies that the request body is an application/x-www-form-urlencoded
+// form with exactly the expected fields and values.
+func MatchFormBody(expected url.Values) Matcher {
+	return NewDescribedMatcher(describeCall("MatchFormBody", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-www-form-urlencoded" {
+			t.Errorf("unexpected content type for form body: %q", r.Header.Get("Content-Type"))
+			return
+		}
+
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			t.Errorf("invalid form body: %s", err.Error())
+			return
+		}
+
+		assert.Equal(t, expected, form)
+	})
+}
+
+// MatchBody verifies that the request body is exactly expected.
+func MatchBody(expected []byte) Matcher {
+	return NewDescribedMatcher(describeCall("MatchBody", expected), matchBody(expected))
+}
+
+// MatchBodyString verifies that the request body is exactly the expected string.
+func MatchBodyString(expected string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchBodyString", expected), matchBody([]byte(expected)))
+}
+
+// matchBody checks that the request body is exactly expected.
+func matchBody(expected []byte) func(t testing.TB, r *http.Request) {
+	return func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		if !bytes.Equal(body, expected) {
+			t.Errorf("unexpected body: got %q, expected %q", body, expected)
+		}
 	}
 }
 
 func MatchJSONBody(jsonBody string) Matcher {
-	return func(t *testing.T, r *http.Request) {
+	return NewDescribedMatcher(describeCall("MatchJSONBody", jsonBody), func(t testing.TB, r *http.Request) {
 		t.Helper()
-		body, err := io.ReadAll(r.Body)
+		body, err := ReadBody(r)
 		if err != nil {
 			t.Error(err.Error())
 			return
 		}
 		assert.JSONEq(t, jsonBody, string(body))
+	})
+}
+
+// MatchJSONPatch verifies that the request body is a JSON Patch (RFC 6902) with
+// the same operations, in the same order, as ops.
+func MatchJSONPatch(ops string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchJSONPatch", ops), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		var expected, actual []map[string]any
+		if err := json.Unmarshal([]byte(ops), &expected); err != nil {
+			t.Errorf("expected json patch is invalid: %s", err.Error())
+			return
+		}
+
+		if err := json.Unmarshal(body, &actual); err != nil {
+			t.Errorf("body is not a json patch: %s", err.Error())
+			return
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// MatchJSONMergePatch verifies that the request body is a JSON Merge Patch (RFC 7396)
+// semantically equal to doc, regardless of the order of its fields.
+func MatchJSONMergePatch(doc string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchJSONMergePatch", doc), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		var expected, actual map[string]any
+		if err := json.Unmarshal([]byte(doc), &expected); err != nil {
+			t.Errorf("expected json merge patch is invalid: %s", err.Error())
+			return
+		}
+
+		if err := json.Unmarshal(body, &actual); err != nil {
+			t.Errorf("body is not a json merge patch: %s", err.Error())
+			return
+		}
+
+		assert.Equal(t, expected, actual)
+	})
+}
+
+// MatchJSONValid verifies that the request body unmarshals into a value of the same
+// type as target, failing on missing fields not tagged with omitempty.
+// Use MatchJSONValidStrict to also fail on unknown fields.
+func MatchJSONValid(target any) Matcher {
+	targetType := jsonTargetType(target)
+
+	return NewDescribedMatcher(describeCall("MatchJSONValid", targetType), matchJSONValid(targetType, false))
+}
+
+// MatchJSONValidStrict verifies that the request body unmarshals into a value of the same
+// type as target, failing on unknown fields and on missing fields not tagged with omitempty.
+func MatchJSONValidStrict(target any) Matcher {
+	targetType := jsonTargetType(target)
+
+	return NewDescribedMatcher(describeCall("MatchJSONValidStrict", targetType), matchJSONValid(targetType, true))
+}
+
+// jsonTargetType returns the type of target, dereferencing pointers.
+func jsonTargetType(target any) reflect.Type {
+	targetType := reflect.TypeOf(target)
+	for targetType.Kind() == reflect.Pointer {
+		targetType = targetType.Elem()
+	}
+
+	return targetType
+}
+
+// matchJSONValid checks that the request body unmarshals into a targetType value,
+// disallowing unknown fields if strict.
+func matchJSONValid(targetType reflect.Type, strict bool) func(t testing.TB, r *http.Request) {
+	return func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+
+		value := reflect.New(targetType).Interface()
+		if err := decoder.Decode(value); err != nil {
+			t.Errorf("body does not unmarshal into %s: %s", targetType, err.Error())
+			return
+		}
+
+		if targetType.Kind() != reflect.Struct {
+			return
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		for _, name := range requiredJSONFields(targetType) {
+			if _, found := fields[name]; !found {
+				t.Errorf("body is missing field %q of %s", name, targetType)
+			}
+		}
+	}
+}
+
+// MatchMultipartFileContent verifies that every file uploaded in the multipart form field
+// has exactly the expected content, reporting each file that differs.
+func MatchMultipartFileContent(field string, expected []byte) Matcher {
+	return NewDescribedMatcher(describeCall("MatchMultipartFileContent", field, expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		form, err := parseMultipartForm(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		files := form.File[field]
+		if len(files) == 0 {
+			t.Errorf("multipart field %q has no files", field)
+			return
+		}
+
+		for i, fh := range files {
+			content, err := readMultipartFile(fh)
+			if err != nil {
+				t.Error(err.Error())
+				continue
+			}
+
+			if !bytes.Equal(expected, content) {
+				t.Errorf(
+					"multipart field %q file %d (%s) content differs: got %q, expected %q",
+					field, i, fh.Filename, content, expected,
+				)
+			}
+		}
+	})
+}
+
+// maxMultipartMemory is the memory limit used to parse multipart forms before using temporary files.
+const maxMultipartMemory = 32 << 20
+
+// MultipartFile describes a file part expected by MatchMultipartForm.
+// Empty attributes are not verified.
+type MultipartFile struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+	// SHA256 is the hex digest of the content, to verify large files without keeping them in the test.
+	SHA256 string
+}
+
+// MatchMultipartForm verifies that the multipart form has the fields with exactly the
+// expected values, and that each files field has exactly the described files, in order.
+// Fields and files not listed are not verified.
+func MatchMultipartForm(fields url.Values, files map[string][]MultipartFile) Matcher {
+	return NewDescribedMatcher(describeCall("MatchMultipartForm", fields, files), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		form, err := parseMultipartForm(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		for field, values := range fields {
+			assert.Equalf(t, values, form.Value[field], "multipart field %q", field)
+		}
+
+		for field, expected := range files {
+			uploaded := form.File[field]
+			if len(uploaded) != len(expected) {
+				t.Errorf("multipart field %q has %d files, expected %d", field, len(uploaded), len(expected))
+				continue
+			}
+
+			for i, fh := range uploaded {
+				matchMultipartFile(t, field, i, fh, expected[i])
+			}
+		}
+	})
+}
+
+// matchMultipartFile verifies the attributes of an uploaded file described by expected.
+func matchMultipartFile(t testing.TB, field string, i int, fh *multipart.FileHeader, expected MultipartFile) {
+	t.Helper()
+
+	if expected.Filename != "" && fh.Filename != expected.Filename {
+		t.Errorf("multipart field %q file %d has filename %q, expected %q", field, i, fh.Filename, expected.Filename)
+	}
+
+	if contentType := fh.Header.Get("Content-Type"); expected.ContentType != "" && contentType != expected.ContentType {
+		t.Errorf("multipart field %q file %d has content type %q, expected %q", field, i, contentType, expected.ContentType)
+	}
+
+	if expected.Content == nil && expected.SHA256 == "" {
+		return
+	}
+
+	content, err := readMultipartFile(fh)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if expected.Content != nil && !bytes.Equal(content, expected.Content) {
+		t.Errorf("multipart field %q file %d (%s) content differs: got %q, expected %q", field, i, fh.Filename, content, expected.Content)
+	}
+
+	sum := sha256.Sum256(content)
+	if digest := hex.EncodeToString(sum[:]); expected.SHA256 != "" && !strings.EqualFold(digest, expected.SHA256) {
+		t.Errorf("multipart field %q file %d (%s) has sha256 %s, expected %s", field, i, fh.Filename, digest, expected.SHA256)
+	}
+}
+
+// MatchMultipartFileCount verifies that exactly n files were uploaded in the multipart form field.
+func MatchMultipartFileCount(field string, n int) Matcher {
+	return NewDescribedMatcher(describeCall("MatchMultipartFileCount", field, n), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		form, err := parseMultipartForm(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		if files := form.File[field]; len(files) != n {
+			t.Errorf("multipart field %q has %d files, expected %d", field, len(files), n)
+		}
+	})
+}
+
+// MatchNonEmptyBody verifies that the request has a body.
+func MatchNonEmptyBody() Matcher {
+	return NewDescribedMatcher(describeCall("MatchNonEmptyBody"), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		if len(body) == 0 {
+			t.Errorf("request body is empty")
+		}
+	})
+}
+
+// MatchBodyHash verifies that the hex digest of the request body, computed with algo
+// ("md5", "sha1", "sha256" or "sha512"), is hexDigest.
+//
+// The body is streamed through the hash without being held in memory, so it is consumed:
+// matchers and Responders running afterwards read it empty, unless the body was already
+// read with ReadBody before it.
+func MatchBodyHash(algo, hexDigest string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchBodyHash", algo, hexDigest), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		newHash, found := bodyHashes[algo]
+		if !found {
+			t.Errorf("unsupported body hash algorithm %q", algo)
+			return
+		}
+
+		h := newHash()
+		if _, err := io.Copy(h, r.Body); err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		actual := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(actual, hexDigest) {
+			t.Errorf("unexpected body %s digest: got %s, expected %s", algo, actual, hexDigest)
+		}
+	})
+}
+
+// bodyHashes are the algorithms supported by MatchBodyHash.
+var bodyHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// parseMultipartForm parses the request multipart form without consuming its body.
+func parseMultipartForm(r *http.Request) (*multipart.Form, error) {
+	body, err := ReadBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := r.Clone(r.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := clone.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+
+	return clone.MultipartForm, nil
+}
+
+func readMultipartFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// requiredJSONFields lists the JSON names of the exported struct fields that are
+// not tagged with omitempty, including the ones promoted from embedded structs.
+func requiredJSONFields(st reflect.Type) []string {
+	var names []string
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		// untagged embedded structs have their fields promoted, as in encoding/json.
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if field.IsExported() || field.Type.Kind() != reflect.Pointer {
+					names = append(names, requiredJSONFields(embedded)...)
+				}
+
+				continue
+			}
+		}
+
+		if !field.IsExported() || strings.Contains(options, "omitempty") {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		names = append(names, name)
 	}
+
+	return names
+}
+
+// ReadBody reads the request body and restores it
+// so the next matchers can read it again.
+// Custom matchers reading the body should use it too.
+func ReadBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
 }
@@ -0,0 +1,289 @@
+package mockhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RecordedRequest is a request received by the MockServer.
+type RecordedRequest struct {
+	Method     string
+	URL        *url.URL
+	Header     http.Header
+	Body       []byte
+	StatusCode int
+
+	// Endpoint is the name of the mocked endpoint that handled the request,
+	// empty when no endpoint matched.
+	Endpoint string
+
+	// FollowedRedirect reports whether this request was sent by a client following
+	// a redirect from a previous request, identified by its Referer header.
+	FollowedRedirect bool
+
+	// ReceivedAt is when the MockServer started reading the request.
+	ReceivedAt time.Time
+
+	// Latency is the time between ReceivedAt and the handler returning, after writing the
+	// whole response. It is not when the client finished reading the response, which the
+	// server cannot observe: writes only block while the connection buffers are full, so
+	// Latency includes the time the client takes to read a large response, except for the
+	// last bytes still in the buffers.
+	Latency time.Duration
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) WriteHeader(statusCode int) {
+	if s.statusCode == 0 {
+		s.statusCode = statusCode
+	}
+
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (s *statusRecorder) recordRaw(status int, _ string, _ []byte) {
+	if s.statusCode == 0 {
+		s.statusCode = status
+	}
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.statusCode == 0 {
+		s.statusCode = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(b)
+}
+
+// handledByKey is the context key holding the endpoint and scenario that handled a request.
+type handledByKey struct{}
+
+// handledBy is the endpoint that handled a request and the index of its dispatched scenario.
+type handledBy struct {
+	endpoint string
+	scenario int
+}
+
+// markHandled records in the request context the endpoint and scenario that handled it.
+func markHandled(r *http.Request, endpoint string, scenario int) {
+	if handled, ok := r.Context().Value(handledByKey{}).(*handledBy); ok {
+		handled.endpoint = endpoint
+		handled.scenario = scenario
+	}
+}
+
+// record is a middleware that stores every request received in order.
+func (ms *MockServer) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAt := time.Now()
+
+		storeMax(&ms.maxInFlight, atomic.AddInt64(&ms.inFlight, 1))
+		defer atomic.AddInt64(&ms.inFlight, -1)
+
+		if conn, ok := r.Context().Value(trackedConnKey{}).(*trackedConn); ok {
+			conn.serve(r.Method + " " + r.URL.RequestURI())
+		}
+
+		body := ms.captureBody(w, r)
+
+		handled := new(handledBy)
+		r = r.WithContext(context.WithValue(r.Context(), handledByKey{}, handled))
+
+		var dw *dumpResponseWriter
+		if ms.dumpTo != nil {
+			dw = &dumpResponseWriter{ResponseWriter: w}
+			w = dw
+		}
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+
+		if sr.statusCode == 0 {
+			sr.statusCode = http.StatusOK
+		}
+
+		latency := time.Since(receivedAt)
+		recordedBody := body()
+
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+
+		recorded := RecordedRequest{
+			Method:           r.Method,
+			URL:              r.URL,
+			Header:           r.Header.Clone(),
+			Body:             recordedBody,
+			StatusCode:       sr.statusCode,
+			Endpoint:         handled.endpoint,
+			FollowedRedirect: ms.isRedirectFollow(r),
+			ReceivedAt:       receivedAt,
+			Latency:          latency,
+		}
+
+		ms.received = append(ms.received, recorded)
+
+		if dw != nil {
+			ms.dump(r, recorded.Body, dw)
+		}
+	})
+}
+
+// OnRequest registers fn to observe every request received, with its context,
+// before it is routed and responded. Observers run in registration order.
+//
+// The context is the server one: deadlines and values set on the client request context
+// are not sent over HTTP, so they cannot be observed. Clients propagating a deadline
+// must send it in a header, such as grpc-timeout.
+//
+// Important: OnRequest MUST be called before Start.
+func (ms *MockServer) OnRequest(fn func(ctx context.Context, r *http.Request)) {
+	ms.observers = append(ms.observers, fn)
+}
+
+// observe is a middleware that runs the request observers.
+func (ms *MockServer) observe(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, fn := range ms.observers {
+			fn(r.Context(), r)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// captureBody returns a function providing the request body for the record.
+//
+// The body is captured as the handlers read it, so they can stream it, and the part they
+// did not read is read after them. Requests expecting 100 Continue only have the part the
+// handlers read captured, so the server only asks for the body if it is needed, unless
+// WithExpectContinue is used to send the interim response upfront.
+func (ms *MockServer) captureBody(w http.ResponseWriter, r *http.Request) func() []byte {
+	expectContinue := strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+	if expectContinue && ms.expectContinue {
+		w.WriteHeader(http.StatusContinue)
+	}
+
+	captured := new(bytes.Buffer)
+	tee := io.TeeReader(r.Body, captured)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{tee, r.Body}
+
+	if expectContinue && !ms.expectContinue {
+		return captured.Bytes
+	}
+
+	return func() []byte {
+		// the body is no longer available if a handler closed it or the
+		// server discarded it to send the response, so it is recorded as read.
+		io.Copy(io.Discard, tee) //nolint:errcheck // best effort
+
+		return captured.Bytes()
+	}
+}
+
+// isRedirectFollow reports whether the request Referer points to a
+// previously received request that was answered with a redirect.
+//
+// Callers must hold ms.mu.
+func (ms *MockServer) isRedirectFollow(r *http.Request) bool {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Path == "" {
+		return false
+	}
+
+	for i := len(ms.received) - 1; i >= 0; i-- {
+		previous := ms.received[i]
+		if previous.URL.RequestURI() != referer.RequestURI() {
+			continue
+		}
+
+		return previous.StatusCode >= 300 && previous.StatusCode <= 399
+	}
+
+	return false
+}
+
+// ReceivedRequests returns every request received by the MockServer in arrival order.
+func (ms *MockServer) ReceivedRequests() []RecordedRequest {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	received := make([]RecordedRequest, len(ms.received))
+	copy(received, ms.received)
+
+	return received
+}
+
+// describeRequests lists the method and URL of the requests handled by an endpoint.
+func describeRequests(received []RecordedRequest, endpoint string) string {
+	var calls []string
+	for _, r := range received {
+		if r.Endpoint == endpoint {
+			calls = append(calls, r.Method+" "+r.URL.RequestURI())
+		}
+	}
+
+	return strings.Join(calls, ", ")
+}
+
+// TotalRequests returns how many requests the MockServer received,
+// including the ones that did not match any endpoint.
+func (ms *MockServer) TotalRequests() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return len(ms.received)
+}
+
+// ConcurrentCalls returns the highest number of simultaneous in-flight requests to the MockServer.
+func (ms *MockServer) ConcurrentCalls() int {
+	return int(atomic.LoadInt64(&ms.maxInFlight))
+}
+
+// ConnectionCount returns how many TCP connections the MockServer accepted,
+// which remains available after Teardown.
+func (ms *MockServer) ConnectionCount() int {
+	return int(atomic.LoadInt64(&ms.connections))
+}
+
+// FindRequests returns the received requests with the given method whose URL path
+// is path, or which were handled by the endpoint registered with the path pattern.
+func (ms *MockServer) FindRequests(method, path string) []RecordedRequest {
+	name := endpointName(method, path)
+
+	var found []RecordedRequest
+	for _, r := range ms.ReceivedRequests() {
+		if r.Method == method && (r.URL.Path == path || r.Endpoint == name) {
+			found = append(found, r)
+		}
+	}
+
+	return found
+}
+
+// LastRequest returns the last request found by FindRequests and whether there was one.
+func (ms *MockServer) LastRequest(method, path string) (RecordedRequest, bool) {
+	found := ms.FindRequests(method, path)
+	if len(found) == 0 {
+		return RecordedRequest{}, false
+	}
+
+	return found[len(found)-1], true
+}
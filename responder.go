@@ -1,38 +1,104 @@
 package mockhttp
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // Responder configures a http.ResponseWriter to send data back.
 type Responder func(w http.ResponseWriter)
 
+// NewDescribedResponder builds a Responder from respond, described by description
+// in Expectations, e.g. `TenantResponse("acme")`.
+//
+// It is not inlined so that every Responder it builds shares the code identified by describedResponderCode.
+//
+//go:noinline
+func NewDescribedResponder(description string, respond func(w http.ResponseWriter)) Responder {
+	return func(w http.ResponseWriter) {
+		if dw, ok := w.(*descriptionWriter); ok {
+			dw.description = description
+			return
+		}
+
+		respond(w)
+	}
+}
+
+// describedResponderCode identifies the function of every Responder built with NewDescribedResponder.
+var describedResponderCode = reflect.ValueOf(NewDescribedResponder("", nil)).Pointer()
+
+// descriptionWriter is the http.ResponseWriter asking a NewDescribedResponder Responder
+// for its description.
+type descriptionWriter struct {
+	http.ResponseWriter
+	description string
+}
+
+// describeResponder returns the description of b, or the name of the function that built it.
+func describeResponder(b Responder) string {
+	if reflect.ValueOf(b).Pointer() != describedResponderCode {
+		return describeFunc(b)
+	}
+
+	dw := new(descriptionWriter)
+	b(dw)
+
+	return dw.description
+}
+
 // ResponseStatusCode is a Responder that defines the response status code.
 func ResponseStatusCode(code int) Responder {
-	return func(w http.ResponseWriter) {
+	return NewDescribedResponder(describeCall("ResponseStatusCode", code), func(w http.ResponseWriter) {
 		w.WriteHeader(code)
-	}
+	})
+}
+
+// ResponseStatus is a Responder that defines the response status code with a custom
+// reason phrase, e.g. ResponseStatus(420, "Enhance Your Calm").
+//
+// Since net/http always writes the standard reason phrase, the connection is hijacked
+// to write the raw response and then closed. When hijacking is not available, as with
+// HTTP/2, the standard reason phrase is used.
+func ResponseStatus(code int, reason string) Responder {
+	return NewDescribedResponder(describeCall("ResponseStatus", code, reason), func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+
+		if mw, ok := w.(*memoryResponseWriter); ok {
+			mw.reason = reason
+		}
+	})
 }
 
 // ResponseHeaders is a Responder that defines the response headers.
 func ResponseHeaders(headers http.Header) Responder {
-	return func(w http.ResponseWriter) {
+	return NewDescribedResponder(describeCall("ResponseHeaders", headers), func(w http.ResponseWriter) {
 		for k, v := range headers {
 			for _, i := range v {
 				w.Header().Add(k, i)
 			}
 		}
-	}
+	})
 }
 
 // JSONResponseBody is a Responder that defines the response body as a JSON string.
 func JSONResponseBody(jsonStr string) Responder {
-	return func(w http.ResponseWriter) {
+	return NewDescribedResponder(describeCall("JSONResponseBody", jsonStr), func(w http.ResponseWriter) {
 		w.Header().Add("Content-Type", "application/json")
 		w.Write([]byte(jsonStr)) //nolint:errcheck // test helper
-	}
+	})
 }
 
 // JSONFileResponseBody is a Responder that defines the response body as a JSON file.
@@ -43,16 +109,325 @@ func JSONFileResponseBody(t *testing.T, filePath string) Responder {
 		return noop
 	}
 
-	return func(w http.ResponseWriter) {
+	return NewDescribedResponder(describeCall("JSONFileResponseBody", filePath), func(w http.ResponseWriter) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(content) //nolint:errcheck // test helper
+	})
+}
+
+// JSONFileResponseBodyValidated is a Responder like JSONFileResponseBody that also
+// fails the test at setup if the file is not well-formed JSON.
+func JSONFileResponseBodyValidated(t *testing.T, filePath string) Responder {
+	t.Helper()
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read json file: %s", err.Error())
+		return noop
+	}
+
+	var fixture any
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		t.Fatalf("json file %s is malformed: %s", filePath, err.Error())
+		return noop
+	}
+
+	return NewDescribedResponder(
+		describeCall("JSONFileResponseBodyValidated", filePath),
+		JSONResponseBody(string(content)),
+	)
+}
+
+// JSONFileResponseBodyLazy is a Responder that defines the response body as a JSON file
+// read on each request, so changes to the file are served without restarting the MockServer.
+// If the file cannot be read, the test fails and the response is 500 Internal Server Error,
+// whatever the other Responders define.
+func JSONFileResponseBodyLazy(filePath string) Responder {
+	return NewDescribedResponder(describeCall("JSONFileResponseBodyLazy", filePath), func(w http.ResponseWriter) {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			failResponse(w, "failed to read json file: %s", err.Error())
+			return
+		}
+
 		w.Header().Add("Content-Type", "application/json")
 		w.Write(content) //nolint:errcheck // test helper
+	})
+}
+
+// ResponseFromSpec is a Responder built from a compact spec with the grammar:
+//
+//	spec   = status *( ";" header ) [ ";" body ]
+//	header = name ":" value
+//
+// e.g. `201; Content-Type: application/json; {"id":1}`. Segments are trimmed of
+// leading spaces and the body is the remainder of the spec after the first segment
+// that is not a header, so it may contain semicolons. It fails the test if the spec is invalid.
+func ResponseFromSpec(t *testing.T, spec string) Responder {
+	statusSegment, rest, _ := strings.Cut(spec, ";")
+
+	status, err := strconv.Atoi(strings.TrimSpace(statusSegment))
+	if err != nil || status < 100 || status > 999 {
+		t.Fatalf("invalid response spec %q: status %q is not a valid status code", spec, statusSegment)
+		return noop
+	}
+
+	headers := make(http.Header)
+
+	var body string
+	for rest != "" {
+		segment, after, _ := strings.Cut(rest, ";")
+
+		name, value, found := strings.Cut(strings.TrimLeft(segment, " "), ":")
+		if !found || !isHeaderName(name) {
+			body = strings.TrimLeft(rest, " ")
+			break
+		}
+
+		headers.Add(name, strings.TrimSpace(value))
+		rest = after
+	}
+
+	return NewDescribedResponder(describeCall("ResponseFromSpec", spec), func(w http.ResponseWriter) {
+		for k, v := range headers {
+			for _, i := range v {
+				w.Header().Add(k, i)
+			}
+		}
+
+		w.WriteHeader(status)
+
+		if body != "" {
+			w.Write([]byte(body)) //nolint:errcheck // test helper
+		}
+	})
+}
+
+// isHeaderName reports whether name is a valid header field name token.
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		isAlphaNum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphaNum && c != '-' && c != '_' {
+			return false
+		}
 	}
+
+	return true
 }
 
 func StringResponseBody(b string) Responder {
-	return func(w http.ResponseWriter) {
+	return NewDescribedResponder(describeCall("StringResponseBody", b), func(w http.ResponseWriter) {
 		w.Write([]byte(b)) //nolint:errcheck // test helper
+	})
+}
+
+// HandlerResponse is a Responder that delegates the response to h.
+//
+// The handler writes directly to the client, so status code and body defined by
+// other Responders are ignored, while headers are still sent. It panics if used
+// outside a MockServer scenario, since the handler needs the request.
+func HandlerResponse(h http.Handler) Responder {
+	return NewDescribedResponder(describeCall("HandlerResponse", h), func(w http.ResponseWriter) {
+		mw, ok := w.(*memoryResponseWriter)
+		if !ok {
+			panic("mockhttp: HandlerResponse can only respond to requests received by a MockServer")
+		}
+
+		mw.handler = h
+	})
+}
+
+// DynamicResponder is a Responder that computes the response from the incoming request,
+// including its chi path parameters. Unlike HandlerResponse, what fn writes is combined
+// with the other Responders, like any other Responder.
+func DynamicResponder(fn func(r *http.Request, w http.ResponseWriter)) Responder {
+	return NewDescribedResponder(describeCall("DynamicResponder", fn), func(w http.ResponseWriter) {
+		fn(requestOf(w), w)
+	})
+}
+
+// RouteInfo describes the chi route matched by a request, as sent by RouteInfoResponse.
+type RouteInfo struct {
+	Pattern string            `json:"pattern"`
+	Method  string            `json:"method"`
+	Params  map[string]string `json:"params"`
+}
+
+// RouteInfoResponse is a Responder that defines the response body as the JSON RouteInfo
+// of the chi route that matched the request, to debug surprising route selection.
+func RouteInfoResponse() Responder {
+	return NewDescribedResponder(describeCall("RouteInfoResponse"), func(w http.ResponseWriter) {
+		r := requestOf(w)
+		if r == nil {
+			return
+		}
+
+		info := RouteInfo{Method: r.Method, Params: make(map[string]string)}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			info.Pattern = rctx.RoutePattern()
+			for i, key := range rctx.URLParams.Keys {
+				info.Params[key] = rctx.URLParams.Values[i]
+			}
+		}
+
+		body, _ := json.Marshal(info)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(body) //nolint:errcheck // test helper
+	})
+}
+
+// NDJSONResponseBody is a Responder that streams the items as newline-delimited JSON,
+// flushing each line to the client and pausing interval between them.
+//
+// Like HandlerResponse, the stream is written directly to the client, so status code
+// and body defined by other Responders are ignored, while headers are still sent.
+func NDJSONResponseBody(items []any, interval time.Duration) Responder {
+	description := describeCall("NDJSONResponseBody", items, interval)
+
+	lines := make([][]byte, len(items))
+	for i, item := range items {
+		line, err := json.Marshal(item)
+		if err != nil {
+			return NewDescribedResponder(description, func(w http.ResponseWriter) {
+				failResponse(w, "failed to marshal nd-json item %d: %s", i, err.Error())
+			})
+		}
+
+		lines[i] = append(line, '\n')
+	}
+
+	return NewDescribedResponder(description, HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		rc := http.NewResponseController(w)
+		for i, line := range lines {
+			if i > 0 {
+				time.Sleep(interval)
+			}
+
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+
+			rc.Flush() //nolint:errcheck // test helper
+		}
+	})))
+}
+
+// GeneratedResponseBody is a Responder that defines the response body as exactly
+// size bytes of deterministic filler, useful to produce large payloads without fixtures.
+func GeneratedResponseBody(size int) Responder {
+	const filler = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	body := make([]byte, size)
+	for i := range body {
+		body[i] = filler[i%len(filler)]
 	}
+
+	return NewDescribedResponder(describeCall("GeneratedResponseBody", size), func(w http.ResponseWriter) {
+		w.Header().Add("Content-Type", "application/octet-stream")
+		w.Write(body) //nolint:errcheck // test helper
+	})
+}
+
+// RedirectResponse is a Responder that redirects the client to location with the given status code.
+func RedirectResponse(location string, code int) Responder {
+	return NewDescribedResponder(describeCall("RedirectResponse", location, code), func(w http.ResponseWriter) {
+		w.Header().Set("Location", location)
+		w.WriteHeader(code)
+	})
+}
+
+// ETagResponseBody is a Responder that defines the response body along with an ETag
+// computed from it. It responds 304 Not Modified when the request If-None-Match matches the ETag.
+func ETagResponseBody(body []byte) Responder {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	return NewDescribedResponder(describeCall("ETagResponseBody", body), func(w http.ResponseWriter) {
+		w.Header().Set("ETag", etag)
+
+		r := requestOf(w)
+		if r != nil && etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Write(body) //nolint:errcheck // test helper
+	})
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LastModifiedResponse is a Responder that defines the Last-Modified header. It responds
+// 304 Not Modified when the request If-Modified-Since is not before modTime and no If-None-Match is sent.
+func LastModifiedResponse(modTime time.Time) Responder {
+	modTime = modTime.UTC().Truncate(time.Second)
+
+	return NewDescribedResponder(describeCall("LastModifiedResponse", modTime), func(w http.ResponseWriter) {
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+
+		r := requestOf(w)
+		if r == nil || r.Header.Get("If-None-Match") != "" {
+			return
+		}
+
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil {
+			return
+		}
+
+		if !modTime.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	})
+}
+
+// GRPCWebResponder is a Responder that defines the response body as gRPC-Web
+// length-prefixed message frames followed by a trailer frame carrying the grpc status.
+func GRPCWebResponder(messages [][]byte, status int) Responder {
+	var body []byte
+	for _, m := range messages {
+		body = appendGRPCWebFrame(body, grpcWebDataFrame, m)
+	}
+
+	trailer := fmt.Sprintf("grpc-status: %d\r\n", status)
+	body = appendGRPCWebFrame(body, grpcWebTrailerFrame, []byte(trailer))
+
+	return NewDescribedResponder(describeCall("GRPCWebResponder", messages, status), func(w http.ResponseWriter) {
+		w.Header().Add("Content-Type", "application/grpc-web+proto")
+		w.Write(body) //nolint:errcheck // test helper
+	})
+}
+
+const (
+	grpcWebDataFrame    byte = 0x00
+	grpcWebTrailerFrame byte = 0x80
+)
+
+func appendGRPCWebFrame(dst []byte, flag byte, payload []byte) []byte {
+	var prefix [5]byte
+	prefix[0] = flag
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(payload)))
+
+	dst = append(dst, prefix[:]...)
+
+	return append(dst, payload...)
 }
 
 //nolint:revive // noop
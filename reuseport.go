@@ -0,0 +1,32 @@
+package mockhttp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// reusedAddresses holds the addresses listened by running MockServers using WithReusePort,
+// since SO_REUSEPORT would otherwise let two of them share a port without error.
+var reusedAddresses sync.Map
+
+// claimAddress reserves the address of l for the MockServer until Teardown,
+// failing when another running MockServer already listens on it.
+func (ms *MockServer) claimAddress(l net.Listener) error {
+	addr := l.Addr().String()
+	if _, loaded := reusedAddresses.LoadOrStore(addr, ms); loaded {
+		return fmt.Errorf("mockhttp: address %s is already used by a running MockServer", addr)
+	}
+
+	ms.claimedAddress = addr
+
+	return nil
+}
+
+// releaseAddress frees the address claimed by the MockServer, if any.
+func (ms *MockServer) releaseAddress() {
+	if ms.claimedAddress != "" {
+		reusedAddresses.Delete(ms.claimedAddress)
+		ms.claimedAddress = ""
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package mockhttp
+
+import "syscall"
+
+// reusePortControl is a no-op on platforms without SO_REUSEPORT.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package mockhttp
+
+import (
+	"strings"
+	"testing"
+)
+
+// Script is an ordered conversation across endpoints. Each step is a Scenario
+// called once, and the scripted endpoints must be called exactly in the step order.
+type Script struct {
+	ms    *MockServer
+	steps []string
+}
+
+// Script creates an empty Script verified by AssertExpectations.
+//
+// Requests to endpoints that are not part of the script are not constrained by it.
+func (ms *MockServer) Script() *Script {
+	s := &Script{ms: ms}
+	ms.scripts = append(ms.scripts, s)
+
+	return s
+}
+
+// Step appends to the script a call to the endpoint with the given HTTP method
+// and path pattern, returning the Scenario that matches and responds to it.
+func (s *Script) Step(method, pattern string, matchers ...Matcher) *Scenario {
+	method = strings.ToUpper(method)
+	s.steps = append(s.steps, endpointName(method, pattern))
+
+	return s.ms.registerEndpoint(method, pattern, matchers...)
+}
+
+// assertScript reports an error if the received requests to the scripted endpoints
+// deviate from the script steps and returns whether the expectation was met.
+func assertScript(t testing.TB, script *Script, received []RecordedRequest) bool {
+	t.Helper()
+
+	scripted := make(map[string]bool, len(script.steps))
+	for _, step := range script.steps {
+		scripted[step] = true
+	}
+
+	var calls []string
+	for _, r := range received {
+		if scripted[r.Endpoint] {
+			calls = append(calls, r.Endpoint)
+		}
+	}
+
+	for i, step := range script.steps {
+		if i >= len(calls) {
+			t.Errorf("script stopped at step %d, expected %s to be called", i+1, step)
+			return false
+		}
+
+		if calls[i] != step {
+			t.Errorf("script deviated at step %d: expected %s, got %s", i+1, step, calls[i])
+			return false
+		}
+	}
+
+	if len(calls) > len(script.steps) {
+		t.Errorf("script ended, but %s was called", calls[len(script.steps)])
+		return false
+	}
+
+	return true
+}
@@ -1,11 +1,21 @@
 package mockhttp
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -20,27 +30,300 @@ func WithPort(port int) Option {
 	}
 }
 
+// WithPortRange makes the MockServer listen on the first TCP port from start to end,
+// inclusive, that is available. Port and URL return the port actually bound.
+func WithPortRange(start, end int) Option {
+	return func(ms *MockServer) {
+		ms.port = start
+		ms.portRangeEnd = end
+	}
+}
+
+// WithNetwork defines the network the MockServer listens on: "tcp" (default),
+// "tcp4" or "tcp6". With "tcp6" the server is reached through the IPv6 loopback.
+func WithNetwork(network string) Option {
+	return func(ms *MockServer) {
+		ms.network = network
+	}
+}
+
+// WithKeepAlivesDisabled makes the MockServer close the connection after
+// each response, so every request uses a fresh connection.
+func WithKeepAlivesDisabled() Option {
+	return func(ms *MockServer) {
+		ms.keepAlivesDisabled = true
+	}
+}
+
+// WithAmbiguousScenariosAsErrors makes Start fail the test, instead of only logging,
+// when an endpoint has scenarios that cannot be told apart by their matchers, either
+// because they have none or because they have the same described matchers.
+func WithAmbiguousScenariosAsErrors() Option {
+	return func(ms *MockServer) {
+		ms.ambiguousAsErrors = true
+	}
+}
+
+// WithClosestScenarioReport makes an endpoint with several scenarios point to the closest one
+// when a request does not match the scenario dispatched by call order, in the test log and in
+// AssertNoMatcherFailures: the scenario the request fully matches, or the one with fewer failed
+// matchers along with its diff.
+//
+// The matchers built with NewMatcher of the other scenarios are tried on a copy of the request,
+// so they should not have side effects. Plain matchers are not tried, as they would fail the test.
+func WithClosestScenarioReport() Option {
+	return func(ms *MockServer) {
+		ms.closestReport = true
+	}
+}
+
+// WithExpectContinue makes the MockServer send an interim 100 Continue response
+// as soon as a request with "Expect: 100-continue" arrives.
+//
+// Without it, 100 Continue is only sent when a matcher or responder reads the body,
+// so scenarios that do not read it reject the upload before the client sends it.
+func WithExpectContinue() Option {
+	return func(ms *MockServer) {
+		ms.expectContinue = true
+	}
+}
+
+// WithAutoGzip makes the MockServer gzip every response body when the client
+// sends "Accept-Encoding: gzip", unless the response already has a Content-Encoding.
+func WithAutoGzip() Option {
+	return func(ms *MockServer) {
+		ms.autoGzip = true
+	}
+}
+
+// WithReusePort sets SO_REUSEPORT on the listener, so a static port can be bound again
+// right after Teardown even while closed connections linger on it. Another running MockServer
+// on the same port is still rejected. It is a no-op on platforms without SO_REUSEPORT.
+func WithReusePort() Option {
+	return func(ms *MockServer) {
+		ms.reusePort = true
+	}
+}
+
+// WithTLS makes the MockServer serve HTTPS with a self-signed certificate, negotiating
+// HTTP/2 or HTTP/1.1 through ALPN. Use Client, after Start, to trust the certificate.
+func WithTLS() Option {
+	return func(ms *MockServer) {
+		ms.tls = true
+	}
+}
+
+// WithAcceptDelay makes the MockServer wait d before accepting each new connection,
+// simulating a server under connection pressure. Unlike delays defined by Responders,
+// it affects only new connections and happens before the request is read.
+//
+// The operating system completes the TCP handshake of pending connections, so clients
+// observe the delay while waiting for the response, not while dialing.
+func WithAcceptDelay(d time.Duration) Option {
+	return func(ms *MockServer) {
+		ms.acceptDelay = d
+	}
+}
+
+// slowListener is a net.Listener that waits before handing over each accepted connection.
+type slowListener struct {
+	net.Listener
+	delay time.Duration
+}
+
+func (l *slowListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	time.Sleep(l.delay)
+
+	return conn, nil
+}
+
+// WithBodyLeakDetection logs, when the test ends, the response bodies the client
+// did not fully read and close.
+//
+// The server detects any client that closes its connection while the body is being sent or
+// stops reading a body while the server is still sending it. Bodies small enough to be
+// buffered by the operating system are only tracked when received through Client.
+func WithBodyLeakDetection() Option {
+	return func(ms *MockServer) {
+		ms.bodyLeakDetection = true
+	}
+}
+
+// WithPrettyJSON makes the MockServer re-indent the JSON response bodies of mocked
+// endpoints before sending them. Invalid JSON and other content types are left untouched.
+func WithPrettyJSON() Option {
+	return func(ms *MockServer) {
+		ms.responseConfig.prettyJSON = true
+	}
+}
+
+// WithGlobalHeaders adds the headers to the responses of every mocked endpoint,
+// unless the scenario Responders define the same header.
+func WithGlobalHeaders(h http.Header) Option {
+	headers := make(http.Header, len(h))
+	for k, values := range h {
+		for _, v := range values {
+			headers.Add(k, v)
+		}
+	}
+
+	return func(ms *MockServer) {
+		ms.responseConfig.headers = headers
+	}
+}
+
+// WithOverflowResponse defines the response sent to requests beyond the calls planned
+// for an endpoint, instead of the response of its last scenario.
+// Those requests still fail the test on AssertExpectations.
+func WithOverflowResponse(builders ...Responder) Option {
+	return func(ms *MockServer) {
+		ms.responseConfig.overflow = builders
+	}
+}
+
+// WithNotFoundResponse defines the response sent to requests that do not match any
+// route, instead of an empty 404 Not Found. Those requests still fail the test.
+//
+// A zero status sends 404 Not Found.
+func WithNotFoundResponse(status int, body []byte, contentType string) Option {
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+
+	return func(ms *MockServer) {
+		ms.notFound = notFoundResponse{status: status, body: body, contentType: contentType}
+	}
+}
+
+// notFoundResponse is the response sent to requests that do not match any route.
+type notFoundResponse struct {
+	status      int
+	body        []byte
+	contentType string
+}
+
+func (nf notFoundResponse) respondTo(w http.ResponseWriter) {
+	if nf.contentType != "" {
+		w.Header().Set("Content-Type", nf.contentType)
+	}
+
+	w.WriteHeader(nf.status)
+
+	if len(nf.body) > 0 {
+		w.Write(nf.body) //nolint:errcheck // test helper
+	}
+}
+
+// IsolationHeader is the request header that identifies the test
+// sending the request when per-test isolation is enabled.
+const IsolationHeader = "X-Mockhttp-Test"
+
+// WithPerTestIsolation makes each endpoint select scenarios independently for each
+// test identifier, so parallel subtests sharing the MockServer do not interleave
+// their response plans. Clients must tag requests with TagRequest, otherwise they share
+// an untagged plan. Times expectations are verified for each test that called the endpoint.
+func WithPerTestIsolation() Option {
+	return func(ms *MockServer) {
+		ms.perTestIsolation = true
+	}
+}
+
+// TagRequest identifies the request as sent by t, for servers using WithPerTestIsolation.
+func TagRequest(r *http.Request, t testing.TB) {
+	r.Header.Set(IsolationHeader, t.Name())
+}
+
+// FailureMode defines how AssertExpectations reports unmet expectations.
+type FailureMode int
+
+const (
+	// AllFailures reports every unmet expectation.
+	AllFailures FailureMode = iota
+	// FirstFailure stops at the first unmet expectation.
+	FirstFailure
+)
+
+// WithFailureMode defines how AssertExpectations reports unmet expectations.
+func WithFailureMode(mode FailureMode) Option {
+	return func(ms *MockServer) {
+		ms.failureMode = mode
+	}
+}
+
 // MockServer is an HTTP testing server designed for easy mocking of REST APIs.
 type MockServer struct {
 	T *testing.T
 
-	port      int
-	server    *httptest.Server
-	router    chi.Router
-	endpoints map[string]*Endpoint
+	port               int
+	portRangeEnd       int
+	network            string
+	keepAlivesDisabled bool
+	ambiguousAsErrors  bool
+	failureMode        FailureMode
+	perTestIsolation   bool
+	closestReport      bool
+	expectContinue     bool
+	autoGzip           bool
+	reusePort          bool
+	claimedAddress     string
+	tls                bool
+	acceptDelay        time.Duration
+	bodyLeakDetection  bool
+	leakCheck          bool
+	responseConfig     responseConfig
+	notFound           notFoundResponse
+	tracer             Tracer
+	dumpTo             io.Writer
+	seed               *int64
+	server             *httptest.Server
+	router             chi.Router
+	endpoints          map[string]*Endpoint
+	patternEndpoints   []*Endpoint
+	matcherGroups      map[string][]Matcher
+	sequences          [][]string
+	scripts            []*Script
+	observers          []func(ctx context.Context, r *http.Request)
+
+	mu            sync.Mutex
+	received      []RecordedRequest
+	trackedBodies []*trackedBody
+
+	state       sync.Map
+	resourceID  int64
+	inFlight    int64
+	maxInFlight int64
+	connections int64
+
+	tracked      *trackingListener
+	running      int32
+	stopped      chan struct{}
+	teardownOnce sync.Once
+	asserted     bool
 }
 
 // NewMockServer creates a MockServer with the provided options.
 func NewMockServer(opts ...Option) *MockServer {
 	mockServer := &MockServer{
-		endpoints: make(map[string]*Endpoint),
-		router: chi.NewRouter(),
+		network:       "tcp",
+		notFound:      notFoundResponse{status: http.StatusNotFound},
+		endpoints:     make(map[string]*Endpoint),
+		matcherGroups: make(map[string][]Matcher),
+		router:        chi.NewRouter(),
 	}
 
 	for _, o := range opts {
 		o(mockServer)
 	}
 
+	if mockServer.responseConfig.chaos != nil {
+		mockServer.responseConfig.chaos.seed(mockServer.seed)
+	}
+
 	return mockServer
 }
 
@@ -53,12 +336,26 @@ func NewMockServer(opts ...Option) *MockServer {
 func (ms *MockServer) Start(t *testing.T) {
 	t.Helper()
 
-	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", ms.port))
+	var lc net.ListenConfig
+	if ms.reusePort {
+		lc.Control = reusePortControl
+	}
+
+	l, err := ms.listen(lc)
 	if err != nil {
 		t.Fatal(err.Error())
 		return
 	}
 
+	if ms.leakCheck || ms.bodyLeakDetection {
+		ms.tracked = newTrackingListener(l)
+		l = ms.tracked
+	}
+
+	if ms.acceptDelay > 0 {
+		l = &slowListener{Listener: l, delay: ms.acceptDelay}
+	}
+
 	routingFuncs := map[string]routingFunc{
 		http.MethodGet:     ms.router.Get,
 		http.MethodPost:    ms.router.Post,
@@ -69,38 +366,241 @@ func (ms *MockServer) Start(t *testing.T) {
 		http.MethodOptions: ms.router.Options,
 	}
 
+	ms.checkAmbiguousScenarios(t)
+
 	for _, endpoint := range ms.endpoints {
-		routing := routingFuncs[endpoint.method]
+		if endpoint.pathRegexp != nil {
+			continue
+		}
+
+		routing, found := routingFuncs[endpoint.method]
+		if !found {
+			ms.router.MethodFunc(endpoint.method, endpoint.path, endpoint.Handler(t))
+			continue
+		}
 
 		routing(endpoint.path, endpoint.Handler(t))
 	}
 
-	server := httptest.NewUnstartedServer(ms.router)
+	var handler http.Handler = ms.router
+	if len(ms.observers) > 0 {
+		handler = ms.observe(handler)
+	}
+
+	if ms.autoGzip {
+		handler = autoGzip(handler)
+	}
+
+	if ms.tracer != nil {
+		handler = ms.trace(handler)
+	}
+
+	server := httptest.NewUnstartedServer(ms.record(handler))
 	server.Listener = l
+	server.Config.SetKeepAlivesEnabled(!ms.keepAlivesDisabled)
+	server.Config.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if conn, ok := c.(*trackedConn); ok {
+			return context.WithValue(ctx, trackedConnKey{}, conn)
+		}
+
+		return ctx
+	}
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&ms.connections, 1)
+		}
+	}
+
+	routePattern := ms.patternRouter(t)
 
 	ms.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		if routePattern(w, r) {
+			return
+		}
+
 		t.Errorf("no matching route found for %s %s", r.Method, r.URL.Path)
-		w.WriteHeader(http.StatusNotFound)
+		ms.notFound.respondTo(w)
 	})
 	ms.router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		if routePattern(w, r) {
+			return
+		}
+
 		t.Errorf("no matching route found for %s %s", r.Method, r.URL.Path)
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	})
 
 	ms.server = server
+	ms.stopped = make(chan struct{})
+	ms.teardownOnce = sync.Once{}
+	ms.asserted = false
 	ms.T = t
 
-	server.Start()
+	if ms.tls {
+		server.EnableHTTP2 = true
+		server.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}, MinVersion: tls.VersionTLS12}
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	atomic.StoreInt32(&ms.running, 1)
 
 	t.Cleanup(func() {
-		ms.AssertExpectations()
+		if ms.bodyLeakDetection {
+			ms.reportLeakedBodies(t)
+		}
+
+		if !ms.asserted {
+			ms.AssertExpectations()
+		}
+
 		ms.Teardown()
+
+		if ms.leakCheck {
+			ms.reportLeakedConnections(t)
+		}
 	})
 }
 
+// checkAmbiguousScenarios reports endpoints with more than one scenario with the same matchers,
+// since those are dispatched only by call order. Matchers are functions, so they are compared
+// by their description: only scenarios without matchers or whose matchers were all built with
+// NewDescribedMatcher, like the built-in ones, can be detected.
+func (ms *MockServer) checkAmbiguousScenarios(t *testing.T) {
+	t.Helper()
+
+	report := t.Logf
+	if ms.ambiguousAsErrors {
+		report = t.Errorf
+	}
+
+	for _, endpoint := range ms.sortedEndpoints() {
+		var sets []string
+		same := make(map[string]int)
+		for _, scenario := range endpoint.scenarios {
+			set, ok := describeMatcherSet(scenario.matchers)
+			if !ok {
+				continue
+			}
+
+			if same[set] == 0 {
+				sets = append(sets, set)
+			}
+			same[set]++
+		}
+
+		for _, set := range sets {
+			if same[set] < 2 {
+				continue
+			}
+
+			if set == "" {
+				report(
+					"endpoint %s has %d scenarios without matchers, they are dispatched only by call order",
+					endpoint.Name(),
+					same[set],
+				)
+				continue
+			}
+
+			report(
+				"endpoint %s has %d scenarios with the same matchers [%s], they are dispatched only by call order",
+				endpoint.Name(),
+				same[set],
+				set,
+			)
+		}
+	}
+}
+
+// describeMatcherSet returns the sorted descriptions of matchers, or false when
+// one of them cannot be compared because its description is not exact.
+func describeMatcherSet(matchers []Matcher) (string, bool) {
+	descriptions := make([]string, len(matchers))
+	for i, m := range matchers {
+		d := m.description()
+		if !d.exact {
+			return "", false
+		}
+
+		descriptions[i] = d.text
+	}
+
+	sort.Strings(descriptions)
+
+	return strings.Join(descriptions, ", "), true
+}
+
+// StartWithContext initializes the MockServer like Start and also tears
+// it down when ctx is canceled, before the test cleanup.
+func (ms *MockServer) StartWithContext(ctx context.Context, t *testing.T) {
+	t.Helper()
+
+	ms.Start(t)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			ms.Teardown()
+		case <-ms.stopped:
+		}
+	}()
+}
+
 // URL returns the HTTP URL where the MockServer is responds.
 func (ms *MockServer) URL() string {
-	return fmt.Sprintf("http://127.0.0.1:%d", ms.Port())
+	scheme := "http"
+	if ms.tls {
+		scheme = "https"
+	}
+
+	return scheme + "://" + net.JoinHostPort(ms.host(), strconv.Itoa(ms.Port()))
+}
+
+// host returns the loopback address used to reach the MockServer on its network.
+func (ms *MockServer) host() string {
+	if ms.network == "tcp6" {
+		return "::1"
+	}
+
+	return "127.0.0.1"
+}
+
+// listen creates the MockServer listener, trying each port of the range
+// defined with WithPortRange until one is available.
+func (ms *MockServer) listen(lc net.ListenConfig) (net.Listener, error) {
+	l, err := ms.listenOnce(lc)
+	for err != nil && ms.port < ms.portRangeEnd {
+		ms.port++
+		l, err = ms.listenOnce(lc)
+	}
+
+	return l, err
+}
+
+// listenOnce listens on the current address of the MockServer.
+func (ms *MockServer) listenOnce(lc net.ListenConfig) (net.Listener, error) {
+	l, err := lc.Listen(context.Background(), ms.network, ms.listenAddress())
+	if err != nil || !ms.reusePort {
+		return l, err
+	}
+
+	if err := ms.claimAddress(l); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// listenAddress returns the address the MockServer listens on.
+func (ms *MockServer) listenAddress() string {
+	host := "localhost"
+	if ms.network != "tcp" {
+		host = ms.host()
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(ms.port))
 }
 
 // Port returns the TCP port where the MockServer is listening.
@@ -118,28 +618,241 @@ func (ms *MockServer) Port() int {
 	return addr.Port
 }
 
+// ExpectSequence expects the endpoints, named by method and pattern (e.g. "GET /books"),
+// to be called in the given relative order, allowing other calls in between.
+// It is verified by AssertExpectations.
+func (ms *MockServer) ExpectSequence(names ...string) {
+	ms.sequences = append(ms.sequences, names)
+}
+
+// BytesServed returns the total size of the response bodies sent by every mocked endpoint.
+func (ms *MockServer) BytesServed() int64 {
+	var total int64
+	for _, endpoint := range ms.endpoints {
+		total += endpoint.BytesServed()
+	}
+
+	return total
+}
+
 // AssertExpectations verifies that every registered name was called at least once.
 func (ms *MockServer) AssertExpectations() {
-	for _, endpoint := range ms.endpoints {
+	ms.assertExpectations(ms.T)
+}
+
+func (ms *MockServer) assertExpectations(t testing.TB) {
+	t.Helper()
+
+	received := ms.ReceivedRequests()
+
+	for _, endpoint := range ms.sortedEndpoints() {
 		for _, scenario := range endpoint.scenarios {
-			if int(scenario.executionCount) == scenario.times {
+			if assertScenario(t, endpoint, scenario, received) && scenario.assertBodiesInOrder(t) {
 				continue
 			}
 
-			if scenario.executionCount == 0 {
-				ms.T.Errorf("endpoint %s was not called", endpoint.Name())
+			if ms.failureMode == FirstFailure {
+				return
+			}
+		}
+	}
+
+	for _, sequence := range ms.sequences {
+		if assertSequence(t, sequence, received) {
+			continue
+		}
+
+		if ms.failureMode == FirstFailure {
+			return
+		}
+	}
+
+	for _, script := range ms.scripts {
+		if assertScript(t, script, received) {
+			continue
+		}
 
+		if ms.failureMode == FirstFailure {
+			return
+		}
+	}
+}
+
+// AssertNoMatcherFailures reports a summary of the matchers that failed on any
+// request received by the mocked endpoints, grouped by scenario.
+func (ms *MockServer) AssertNoMatcherFailures(t testing.TB) {
+	t.Helper()
+
+	var summary []string
+	for _, endpoint := range ms.sortedEndpoints() {
+		for i, scenario := range endpoint.scenarios {
+			mismatches := atomic.LoadInt64(&scenario.mismatchCount)
+			if mismatches == 0 {
 				continue
 			}
 
-			ms.T.Errorf(
-				"endpoint %s was called %d times, expected was %d",
+			scenario.mu.Lock()
+			failures := strings.Join(scenario.matchFailures, "; ")
+			scenario.mu.Unlock()
+
+			summary = append(summary, fmt.Sprintf(
+				"endpoint %s scenario %d%s failed matchers on %d of %d requests: %s",
 				endpoint.Name(),
-				scenario.executionCount,
-				scenario.times,
-			)
+				i+1,
+				scenario.describeMeta(),
+				mismatches,
+				scenario.TimesCalled(),
+				failures,
+			))
+		}
+	}
+
+	if len(summary) > 0 {
+		t.Errorf("matchers failed:\n%s", strings.Join(summary, "\n"))
+	}
+}
+
+// assertSequence reports an error if the received requests do not contain the
+// endpoints in the sequence relative order and returns whether the expectation was met.
+func assertSequence(t testing.TB, sequence []string, received []RecordedRequest) bool {
+	t.Helper()
+
+	next := 0
+	for _, r := range received {
+		if next < len(sequence) && r.Endpoint == sequence[next] {
+			next++
+		}
+	}
+
+	if next == len(sequence) {
+		return true
+	}
+
+	t.Errorf(
+		"endpoints were not called in sequence %v, endpoint %s was not called after the previous ones",
+		sequence,
+		sequence[next],
+	)
+
+	return false
+}
+
+// WaitAndAssert blocks until every scenario was called at least the expected number of times,
+// or the timeout expires, and then verifies the expectations like AssertExpectations.
+//
+// Use it as a synchronization point for clients that send requests asynchronously.
+// The expectations are not verified again when the test ends, so failures are reported once.
+func (ms *MockServer) WaitAndAssert(t *testing.T, timeout time.Duration) {
+	t.Helper()
+
+	const interval = 10 * time.Millisecond
+
+	deadline := time.Now().Add(timeout)
+	for !ms.expectedCallsReceived() && time.Now().Before(deadline) {
+		time.Sleep(interval)
+	}
+
+	ms.assertExpectations(t)
+	ms.asserted = true
+}
+
+// expectedCallsReceived reports whether every scenario was called at least the expected number of times.
+func (ms *MockServer) expectedCallsReceived() bool {
+	for _, endpoint := range ms.endpoints {
+		for _, scenario := range endpoint.scenarios {
+			if scenario.TimesCalled() < scenario.expectedTimes() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// assertScenario reports an error if the scenario was not called the expected
+// number of times and returns whether the expectation was met.
+// The error lists the requests received by the endpoint to ease diagnosis.
+func assertScenario(t testing.TB, endpoint *Endpoint, scenario *Scenario, received []RecordedRequest) bool {
+	t.Helper()
+
+	if endpoint.isolated {
+		return assertIsolatedScenario(t, endpoint, scenario)
+	}
+
+	called := scenario.TimesCalled()
+	if called == scenario.expectedTimes() {
+		return true
+	}
+
+	if called == 0 {
+		t.Errorf("endpoint %s was not called%s", endpoint.Name(), scenario.describeMeta())
+
+		return false
+	}
+
+	t.Errorf(
+		"endpoint %s was called %d times, expected was %d, received requests: %s%s",
+		endpoint.Name(),
+		called,
+		scenario.expectedTimes(),
+		describeRequests(received, endpoint.Name()),
+		scenario.describeMeta(),
+	)
+
+	return false
+}
+
+// assertIsolatedScenario verifies the Times expectation for each test that called the scenario.
+func assertIsolatedScenario(t testing.TB, endpoint *Endpoint, scenario *Scenario) bool {
+	t.Helper()
+
+	calls := scenario.testCalls()
+	if len(calls) == 0 {
+		t.Errorf("endpoint %s was not called%s", endpoint.Name(), scenario.describeMeta())
+
+		return false
+	}
+
+	ids := make([]string, 0, len(calls))
+	for id := range calls {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	met := true
+	for _, id := range ids {
+		if calls[id] == scenario.expectedTimes() {
+			continue
 		}
+
+		met = false
+
+		t.Errorf(
+			"endpoint %s was called %d times by test %q, expected was %d%s",
+			endpoint.Name(),
+			calls[id],
+			id,
+			scenario.expectedTimes(),
+			scenario.describeMeta(),
+		)
 	}
+
+	return met
+}
+
+// sortedEndpoints returns the endpoints ordered by name.
+func (ms *MockServer) sortedEndpoints() []*Endpoint {
+	endpoints := make([]*Endpoint, 0, len(ms.endpoints))
+	for _, endpoint := range ms.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Name() < endpoints[j].Name()
+	})
+
+	return endpoints
 }
 
 // Get creates a mock name for a get request.
@@ -172,12 +885,66 @@ func (ms *MockServer) Head(pattern string, matchers ...Matcher) *Scenario {
 	return ms.registerEndpoint(http.MethodHead, pattern, matchers...)
 }
 
+// GetMatching creates a mock name for a get request whose path matches the regular expression.
+func (ms *MockServer) GetMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodGet, re, matchers...)
+}
+
+// PostMatching creates a mock name for a post request whose path matches the regular expression.
+func (ms *MockServer) PostMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodPost, re, matchers...)
+}
+
+// PutMatching creates a mock name for a put request whose path matches the regular expression.
+func (ms *MockServer) PutMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodPut, re, matchers...)
+}
+
+// PatchMatching creates a mock name for a patch request whose path matches the regular expression.
+func (ms *MockServer) PatchMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodPatch, re, matchers...)
+}
+
+// DeleteMatching creates a mock name for a delete request whose path matches the regular expression.
+func (ms *MockServer) DeleteMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodDelete, re, matchers...)
+}
+
+// HeadMatching creates a mock name for a head request whose path matches the regular expression.
+func (ms *MockServer) HeadMatching(re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	return ms.registerPatternEndpoint(http.MethodHead, re, matchers...)
+}
+
+// GetParam creates a mock name for a get request whose response is computed by responses
+// from the value of the last path parameter in pattern, as in "/users/{id}".
+func (ms *MockServer) GetParam(pattern string, responses func(param string) []Responder, matchers ...Matcher) *Scenario {
+	params := pathParamRegexp.FindAllStringSubmatch(pattern, -1)
+	if len(params) == 0 {
+		panic("mockhttp: GetParam pattern " + pattern + " has no path parameter")
+	}
+
+	name := params[len(params)-1][1]
+
+	return ms.Get(pattern, matchers...).Respond(func(w http.ResponseWriter) {
+		param := chi.URLParam(requestOf(w), name)
+		for _, responder := range responses(param) {
+			responder(w)
+		}
+	})
+}
+
+// pathParamRegexp captures the name of the path parameters of a chi route pattern.
+var pathParamRegexp = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
 func (ms *MockServer) getEndpoint(method, path string) *Endpoint {
 	if e, found := ms.endpoints[endpointName(method, path)]; found {
 		return e
 	}
 
 	newE := newEndpoint(method, path)
+	newE.isolated = ms.perTestIsolation
+	newE.closestReport = ms.closestReport
+	newE.responseConfig = ms.responseConfig
 	ms.endpoints[newE.Name()] = newE
 
 	return newE
@@ -194,6 +961,144 @@ func (ms *MockServer) registerEndpoint(method string, pattern string, matchers .
 	return scenario
 }
 
+func (ms *MockServer) registerPatternEndpoint(method string, re *regexp.Regexp, matchers ...Matcher) *Scenario {
+	name := patternEndpointName(method, re)
+
+	endpoint, found := ms.endpoints[name]
+	if !found {
+		endpoint = newPatternEndpoint(method, re)
+		endpoint.isolated = ms.perTestIsolation
+		endpoint.closestReport = ms.closestReport
+		endpoint.responseConfig = ms.responseConfig
+		ms.endpoints[name] = endpoint
+		ms.patternEndpoints = append(ms.patternEndpoints, endpoint)
+	}
+
+	scenario := newScenario(matchers)
+	endpoint.AddScenario(scenario)
+
+	return scenario
+}
+
+// patternRouter dispatches requests not routed by chi to the first
+// regular expression endpoint matching its method and path.
+// It reports whether the request was handled.
+func (ms *MockServer) patternRouter(t *testing.T) func(w http.ResponseWriter, r *http.Request) bool {
+	t.Helper()
+
+	handlers := make([]http.HandlerFunc, len(ms.patternEndpoints))
+	for i, endpoint := range ms.patternEndpoints {
+		handlers[i] = endpoint.Handler(t)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		for i, endpoint := range ms.patternEndpoints {
+			if endpoint.method == r.Method && endpoint.pathRegexp.MatchString(r.URL.Path) {
+				handlers[i](w, r)
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// DefineMatcherGroup stores a named set of matchers to be reused with Use.
+func (ms *MockServer) DefineMatcherGroup(name string, matchers ...Matcher) {
+	ms.matcherGroups[name] = matchers
+}
+
+// Use returns a Matcher that applies every matcher of a group defined with DefineMatcherGroup.
+//
+// The group is expanded when Use is called, so it must be defined beforehand.
+func (ms *MockServer) Use(name string) Matcher {
+	matchers, found := ms.matcherGroups[name]
+	if !found {
+		return NewMatcher(func(t testing.TB, r *http.Request) {
+			t.Helper()
+			t.Errorf("matcher group %q is not defined", name)
+		})
+	}
+
+	return func(t *testing.T, r *http.Request) {
+		t.Helper()
+		for _, m := range matchers {
+			m(t, r)
+		}
+	}
+}
+
+// State returns a concurrency-safe map shared by every endpoint, allowing
+// dynamic responders to model a stateful backend.
+func (ms *MockServer) State() *sync.Map {
+	return &ms.state
+}
+
+// SetState stores a value in the server state.
+func (ms *MockServer) SetState(key string, value any) {
+	ms.state.Store(key, value)
+}
+
+// GetState returns a value from the server state and whether it was found.
+func (ms *MockServer) GetState(key string) (any, bool) {
+	return ms.state.Load(key)
+}
+
+// Resource models a create-then-read REST resource backed by the server state.
+//
+// A POST to path stores the request body and Content-Type under a generated ID and responds 201
+// with a Location header, while a GET to path/{id} responds with the stored body or 404.
+// As with custom handlers on Router, these routes are not asserted.
+func (ms *MockServer) Resource(path string) {
+	path = strings.TrimSuffix(path, "/")
+
+	ms.router.Post(path, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id := strconv.FormatInt(atomic.AddInt64(&ms.resourceID, 1), 10)
+		location := path + "/" + id
+
+		stored := storedResource{contentType: r.Header.Get("Content-Type"), body: body}
+		ms.SetState(location, stored)
+
+		stored.setContentType(w)
+
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body) //nolint:errcheck // test helper
+	})
+
+	ms.router.Get(path+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		value, found := ms.GetState(path + "/" + chi.URLParam(r, "id"))
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		stored, _ := value.(storedResource)
+		stored.setContentType(w)
+		w.Write(stored.body) //nolint:errcheck // test helper
+	})
+}
+
+// storedResource is a resource created by a POST to a Resource path.
+type storedResource struct {
+	contentType string
+	body        []byte
+}
+
+// setContentType sets the Content-Type the resource was created with, if any,
+// leaving it to be sniffed from the body otherwise.
+func (s storedResource) setContentType(w http.ResponseWriter) {
+	if s.contentType != "" {
+		w.Header().Set("Content-Type", s.contentType)
+	}
+}
+
 // Router exposes the internal chi.Router to allow configurations not supported by the helper methods.
 func (ms *MockServer) Router() chi.Router {
 	return ms.router
@@ -207,6 +1112,19 @@ func (ms *MockServer) Server() *httptest.Server {
 // Teardown stops the HTTP server.
 //
 // Call this with a defer after starting the server.
+//
+// It is safe to call it more than once.
 func (ms *MockServer) Teardown() {
-	ms.server.Close()
+	ms.teardownOnce.Do(func() {
+		atomic.StoreInt32(&ms.running, 0)
+		close(ms.stopped)
+		ms.server.Close()
+		ms.releaseAddress()
+	})
+}
+
+// IsRunning reports whether the MockServer was started and not torn down yet,
+// so URL and Port can be called.
+func (ms *MockServer) IsRunning() bool {
+	return atomic.LoadInt32(&ms.running) == 1
 }
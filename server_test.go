@@ -1,15 +1,39 @@
 package mockhttp
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,6 +65,19 @@ func TestMockServer(t *testing.T) {
 		require.Equal(t, "http://127.0.0.1:60000", ms.URL())
 	})
 
+	t.Run("start mock server at first available port in range", func(t *testing.T) {
+		taken, err := net.Listen("tcp", "localhost:60000")
+		require.NoError(t, err)
+		defer taken.Close()
+
+		ms := NewMockServer(WithPortRange(60000, 60002))
+		ms.Start(t)
+		defer ms.Teardown()
+
+		require.Equal(t, 60001, ms.Port())
+		require.Equal(t, "http://127.0.0.1:60001", ms.URL())
+	})
+
 	t.Run("start mock server at any port available", func(t *testing.T) {
 		ms := NewMockServer()
 		ms.Start(t)
@@ -53,6 +90,136 @@ func TestMockServer(t *testing.T) {
 		}, 2*time.Second, 200*time.Millisecond)
 	})
 
+	t.Run("report connections leaked after teardown", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		for _, leak := range []bool{false, true} {
+			ms := NewMockServer(WithLeakCheck())
+
+			ms.Get("/get").Respond(HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !leak {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				conn, _, err := http.NewResponseController(w).Hijack()
+				require.NoError(t, err)
+
+				go func() {
+					<-release
+					conn.Close()
+				}()
+
+				conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n")) //nolint:errcheck // test helper
+			})))
+
+			ms.Start(new(testing.T))
+
+			_, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			ms.Teardown()
+
+			// a server of another test does not affect the report.
+			other := NewMockServer()
+			other.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+			other.Start(new(testing.T))
+
+			_, err = http.Get(other.URL() + "/get")
+			require.NoError(t, err)
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.reportLeakedConnections(counter)
+
+			other.Teardown()
+
+			if leak {
+				require.Equal(t, 1, counter.failures)
+				require.Contains(t, counter.messages[0], "is still open after teardown")
+			} else {
+				require.Zero(t, counter.failures)
+			}
+		}
+	})
+
+	t.Run("count accepted connections", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Times(4).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+
+		pooled := &http.Client{Transport: &http.Transport{}}
+		for i := 0; i < 3; i++ {
+			response, err := pooled.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+			require.NoError(t, response.Body.Close())
+		}
+
+		unpooled := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+		_, err := unpooled.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		pooled.CloseIdleConnections()
+		ms.Teardown()
+
+		require.Equal(t, 2, ms.ConnectionCount())
+	})
+
+	t.Run("delay accepting new connections", func(t *testing.T) {
+		delay := 100 * time.Millisecond
+		ms := NewMockServer(WithPort(60000), WithAcceptDelay(delay))
+
+		ms.Get("/get").Times(3).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		// the server sits idle longer than the delay, which must not use it up.
+		time.Sleep(2 * delay)
+
+		client := &http.Client{Transport: &http.Transport{}}
+		defer client.CloseIdleConnections()
+
+		start := time.Now()
+
+		_, err := client.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.GreaterOrEqual(t, time.Since(start), delay)
+
+		// the connection is reused, so it is not delayed again.
+		reused := time.Now()
+
+		_, err = client.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Less(t, time.Since(reused), delay)
+
+		other := &http.Client{Transport: &http.Transport{}}
+		defer other.CloseIdleConnections()
+
+		dialed := time.Now()
+
+		_, err = other.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.GreaterOrEqual(t, time.Since(dialed), delay)
+	})
+
+	t.Run("report whether mock server is running", func(t *testing.T) {
+		ms := NewMockServer()
+		require.False(t, ms.IsRunning())
+
+		ms.Start(t)
+		require.True(t, ms.IsRunning())
+
+		ms.Teardown()
+		require.False(t, ms.IsRunning())
+	})
+
 	t.Run("fail if unmapped route is called", func(t *testing.T) {
 		mockT := new(testing.T)
 
@@ -472,27 +639,3480 @@ func TestMockServer(t *testing.T) {
 		ms.AssertExpectations()
 		require.True(t, mockT.Failed())
 	})
-}
 
-// This uses the built-in cleanup to perform
-// a integration test similar to what the lib user
-// should write.
-func TestMockServer_Cleanup(t *testing.T) {
-	ms := NewMockServer()
+	t.Run("mock request with status computed by call count", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
 
-	ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Get("/get").Times(3).StatusByCall(func(n int) int {
+			if n < 2 {
+				return http.StatusAccepted
+			}
+			return http.StatusOK
+		})
 
-	ms.Start(t)
+		ms.Start(t)
+		defer ms.Teardown()
 
-	var response *http.Response
-	require.Eventually(t, func() bool {
-		r, err := http.Get(ms.URL() + "/get")
-		if err != nil {
-			return false
+		expected := []int{http.StatusAccepted, http.StatusAccepted, http.StatusOK}
+		for i, code := range expected {
+			r, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equalf(t, code, r.StatusCode, "request %d was wrong", i)
 		}
-		response = r
-		return true
-	}, 2*time.Second, 200*time.Millisecond)
+	})
 
-	require.Equal(t, http.StatusNoContent, response.StatusCode)
+	t.Run("mock request with grpc-web response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/grpc.Service/Method").Respond(
+			GRPCWebResponder([][]byte{[]byte("hello")}, 0),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Post(ms.URL()+"/grpc.Service/Method", "application/grpc-web+proto", nil)
+		require.NoError(t, err)
+
+		require.Equal(t, "application/grpc-web+proto", response.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		expected := append([]byte{0x00, 0, 0, 0, 5}, "hello"...)
+		expected = append(expected, 0x80, 0, 0, 0, 16)
+		expected = append(expected, "grpc-status: 0\r\n"...)
+		require.Equal(t, expected, body)
+	})
+
+	t.Run("report whether all scenario matchers passed", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		matching := ms.Get(
+			"/get",
+			MatchQueryParams(url.Values{"foo": []string{"bar"}}),
+		).Respond(ResponseStatusCode(http.StatusNoContent))
+		mismatching := ms.Post(
+			"/post",
+			MatchQueryParams(url.Values{"foo": []string{"bar"}}),
+		).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get?foo=bar")
+		require.NoError(t, err)
+
+		_, err = http.Post(ms.URL()+"/post?foo=baz", "text/html", nil)
+		require.NoError(t, err)
+
+		require.True(t, matching.AllMatched())
+		require.False(t, mismatching.AllMatched())
+	})
+
+	t.Run("report whether plain matchers passed", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		scenario := ms.Get("/get", func(t *testing.T, r *http.Request) {
+			if r.URL.Query().Get("foo") != "bar" {
+				t.Errorf("unexpected foo: %s", r.URL.Query().Get("foo"))
+			}
+		}).Times(2).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get?foo=bar")
+		require.NoError(t, err)
+		require.True(t, scenario.AllMatched())
+
+		_, err = http.Get(ms.URL() + "/get?foo=baz")
+		require.NoError(t, err)
+		require.False(t, scenario.AllMatched())
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("report whether plain matchers passed after the test failed", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		matchFoo := func(t *testing.T, r *http.Request) {
+			if r.URL.Query().Get("foo") != "bar" {
+				t.Errorf("unexpected foo: %s", r.URL.Query().Get("foo"))
+			}
+		}
+
+		first := ms.Get("/get", matchFoo).Respond(ResponseStatusCode(http.StatusNoContent))
+		second := ms.Post("/post", matchFoo).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get?foo=baz")
+		require.NoError(t, err)
+		require.False(t, first.AllMatched())
+		require.True(t, mockT.Failed())
+
+		_, err = http.Post(ms.URL()+"/post?foo=baz", "text/plain", nil)
+		require.NoError(t, err)
+		require.False(t, second.AllMatched())
+	})
+
+	t.Run("start mock server with keep-alives disabled", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithKeepAlivesDisabled())
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+		require.True(t, response.Close)
+	})
+
+	t.Run("mock request with json valid matcher", func(t *testing.T) {
+		type audit struct {
+			CreatedBy string `json:"createdBy"`
+		}
+
+		type book struct {
+			audit
+			Title string `json:"title"`
+			ISBN  string `json:"isbn,omitempty"`
+		}
+
+		testCases := []struct {
+			matcher Matcher
+			body    string
+			failed  bool
+		}{
+			{matcher: MatchJSONValid(book{}), body: `{"title": "Foundation", "createdBy": "isaac"}`, failed: false},
+			{
+				matcher: MatchJSONValid(book{}),
+				body:    `{"title": "Foundation", "isbn": "9780345317988", "createdBy": "isaac"}`,
+				failed:  false,
+			},
+			{matcher: MatchJSONValid(book{}), body: `{"title": "Foundation", "author": "Asimov", "createdBy": "isaac"}`, failed: false},
+			{matcher: MatchJSONValid(book{}), body: `{"isbn": "9780345317988", "createdBy": "isaac"}`, failed: true},
+			{matcher: MatchJSONValid(&book{}), body: `{"title": "Foundation"}`, failed: true},
+			{matcher: MatchJSONValidStrict(book{}), body: `{"title": "Foundation", "createdBy": "isaac"}`, failed: false},
+			{
+				matcher: MatchJSONValidStrict(book{}),
+				body:    `{"title": "Foundation", "author": "Asimov", "createdBy": "isaac"}`,
+				failed:  true,
+			},
+			{matcher: MatchJSONValidStrict(book{}), body: `{"title": "Foundation"}`, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/post", tc.matcher).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			response, err := http.Post(ms.URL()+"/post", "application/json", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusCreated, response.StatusCode)
+			require.Equalf(t, tc.failed, mockT.Failed(), "body %s", tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with path pattern", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.GetMatching(regexp.MustCompile(`^/users/[0-9]+$`)).
+			Times(2).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for _, path := range []string{"/users/1", "/users/42"} {
+			response, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusNoContent, response.StatusCode)
+		}
+	})
+
+	t.Run("mock request with path pattern alongside same literal path", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/users").Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.GetMatching(regexp.MustCompile(`/users`)).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for path, code := range map[string]int{"/users": http.StatusAccepted, "/users/1": http.StatusNoContent} {
+			response, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+
+			require.Equal(t, code, response.StatusCode)
+		}
+
+		expectations := ms.Expectations()
+		require.Len(t, expectations, 2)
+		require.Equal(t, "GET /users", expectations[0].Endpoint)
+		require.Equal(t, "GET ~/users", expectations[1].Endpoint)
+	})
+
+	t.Run("fail if path does not match any pattern", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.GetMatching(regexp.MustCompile(`^/users/[0-9]+$`)).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/users/foo")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("fail if scenarios are ambiguous", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithAmbiguousScenariosAsErrors())
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("fail if scenarios have the same matchers", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithAmbiguousScenariosAsErrors())
+
+		ms.Get("/get", MatchHeaderPresent("X-Id"), MatchQueryParam("foo", "bar")).
+			Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Get("/get", MatchQueryParam("foo", "bar"), MatchHeaderPresent("X-Id")).
+			Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("do not fail if scenarios have different or custom matchers", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithAmbiguousScenariosAsErrors())
+
+		ms.Get("/get", MatchHeaderPresent("X-Id")).Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Get("/get", MatchHeaderPresent("X-Other")).Respond(ResponseStatusCode(http.StatusOK))
+
+		matchTenant := func(tenant string) Matcher {
+			return NewMatcher(func(t testing.TB, r *http.Request) {
+				if r.Header.Get("X-Tenant") != tenant {
+					t.Errorf("tenant is not %s", tenant)
+				}
+			})
+		}
+
+		ms.Post("/post", matchTenant("foo")).Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Post("/post", matchTenant("bar")).Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Put("/put").
+			ExpectJSON(func(decoded map[string]any) error {
+				if decoded["kind"] != "book" {
+					return errors.New("kind is not book")
+				}
+				return nil
+			}).
+			Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Put("/put").
+			ExpectJSON(func(decoded map[string]any) error {
+				if decoded["kind"] != "author" {
+					return errors.New("kind is not author")
+				}
+				return nil
+			}).
+			Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Patch("/patch", MatchJWTClaim([]byte("foo"), "sub", "1")).Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Patch("/patch", MatchJWTClaim([]byte("bar"), "sub", "1")).Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		require.False(t, mockT.Failed())
+	})
+
+	t.Run("mock request with matcher group", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.DefineMatcherGroup(
+			"app",
+			MatchHeader(http.Header{"X-App": []string{"foo"}}),
+			MatchQueryParams(url.Values{"foo": []string{"bar"}}),
+		)
+
+		ms.Get("/get", ms.Use("app")).Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Post("/post", ms.Use("undefined")).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get?foo=bar", http.NoBody)
+		require.NoError(t, err)
+
+		request.Header.Set("X-App", "foo")
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+		require.False(t, mockT.Failed())
+
+		_, err = http.Post(ms.URL()+"/post", "text/html", nil)
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("get total bytes served by mock server", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Times(2).Respond(StringResponseBody("hello"))
+		ms.Post("/post").Respond(JSONResponseBody(`{"result": true}`))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for i := 0; i < 2; i++ {
+			_, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+		}
+
+		_, err := http.Post(ms.URL()+"/post", "text/html", nil)
+		require.NoError(t, err)
+
+		require.Equal(t, int64(26), ms.BytesServed())
+	})
+
+	t.Run("mock request with conditional response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		modTime := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+		ms.Get("/get").Times(3).Respond(
+			ETagResponseBody([]byte(`{"result": true}`)),
+			LastModifiedResponse(modTime),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		first, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		require.Equal(t, modTime.Format(http.TimeFormat), first.Header.Get("Last-Modified"))
+
+		etag := first.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+		require.NoError(t, err)
+
+		request.Header.Set("If-None-Match", etag)
+
+		second, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotModified, second.StatusCode)
+
+		request, err = http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+		require.NoError(t, err)
+
+		request.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+
+		third, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotModified, third.StatusCode)
+	})
+
+	t.Run("record redirect chain followed by client", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/old").Respond(RedirectResponse("/new", http.StatusFound))
+		ms.Get("/new").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/old")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+		received := ms.ReceivedRequests()
+		require.Len(t, received, 2)
+
+		require.Equal(t, "/old", received[0].URL.Path)
+		require.Equal(t, http.StatusFound, received[0].StatusCode)
+		require.False(t, received[0].FollowedRedirect)
+
+		require.Equal(t, "/new", received[1].URL.Path)
+		require.Equal(t, http.StatusNoContent, received[1].StatusCode)
+		require.True(t, received[1].FollowedRedirect)
+	})
+
+	t.Run("record request body streamed to matchers", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		firstRead := make(chan struct{})
+		ms.Post("/post", NewMatcher(func(t testing.TB, r *http.Request) {
+			first := make([]byte, len("first"))
+			_, err := io.ReadFull(r.Body, first)
+			require.NoError(t, err)
+
+			close(firstRead)
+		})).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		body, pw := io.Pipe()
+		streamed := make(chan bool, 1)
+		go func() {
+			pw.Write([]byte("first")) //nolint:errcheck // read by the server
+
+			select {
+			case <-firstRead:
+				streamed <- true
+			case <-time.After(time.Second):
+				streamed <- false
+			}
+
+			pw.Write([]byte("second")) //nolint:errcheck // read by the server
+			pw.Close()
+		}()
+
+		response, err := http.Post(ms.URL()+"/post", "text/plain", body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+		require.True(t, <-streamed, "the matcher did not read the body before it was sent")
+
+		// waits for the record of the in-flight request
+		ms.Teardown()
+
+		received := ms.ReceivedRequests()
+		require.Len(t, received, 1)
+		require.Equal(t, "firstsecond", string(received[0].Body))
+	})
+
+	t.Run("dump requests and responses", func(t *testing.T) {
+		dump := new(bytes.Buffer)
+		ms := NewMockServer(WithPort(60000), WithDumpTo(dump))
+
+		ms.Post("/post").Respond(
+			ResponseStatusCode(http.StatusCreated),
+			JSONResponseBody(`{"id":1}`),
+		)
+
+		ms.Start(t)
+
+		_, err := http.Post(ms.URL()+"/post", "application/json", strings.NewReader(`{"name":"a"}`))
+		require.NoError(t, err)
+
+		// waits for the dump of the in-flight request
+		ms.Teardown()
+
+		transcript := dump.String()
+		require.Contains(t, transcript, "POST /post HTTP/1.1\r\n")
+		require.Contains(t, transcript, "Content-Type: application/json\r\n")
+		require.Contains(t, transcript, `{"name":"a"}`)
+		require.Contains(t, transcript, "HTTP/1.1 201 Created\r\n")
+		require.Contains(t, transcript, `{"id":1}`)
+	})
+
+	t.Run("observe requests before they are responded", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		var (
+			observed          []string
+			hasLocalAddr      bool
+			observedByHandler []string
+		)
+		ms.OnRequest(func(ctx context.Context, r *http.Request) {
+			_, hasLocalAddr = ctx.Value(http.LocalAddrContextKey).(net.Addr)
+
+			observed = append(observed, r.Method+" "+r.URL.Path)
+		})
+
+		ms.Get("/get").Respond(HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			observedByHandler = append([]string(nil), observed...)
+			w.WriteHeader(http.StatusNoContent)
+		})))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+		require.True(t, hasLocalAddr)
+		require.Equal(t, []string{"GET /get"}, observedByHandler)
+		require.Equal(t, []string{"GET /get"}, observed)
+	})
+
+	t.Run("record request latency", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		delay := 50 * time.Millisecond
+		ms.Get("/get").Respond(HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(http.StatusOK)
+		})))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		before := time.Now()
+
+		_, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		recorded, ok := ms.LastRequest(http.MethodGet, "/get")
+		require.True(t, ok)
+
+		require.False(t, recorded.ReceivedAt.Before(before))
+		require.GreaterOrEqual(t, recorded.Latency, delay)
+		require.Less(t, recorded.Latency, time.Since(before))
+	})
+
+	t.Run("record latency of slowly read large response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(GeneratedResponseBody(32 << 20))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		delay := 200 * time.Millisecond
+		time.Sleep(delay)
+
+		_, err = io.Copy(io.Discard, response.Body)
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+
+		recorded, ok := ms.LastRequest(http.MethodGet, "/get")
+		require.True(t, ok)
+
+		require.GreaterOrEqual(t, recorded.Latency, delay)
+	})
+
+	t.Run("mock request with generated response body", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		size := 1 << 20
+		ms.Get("/get").Respond(GeneratedResponseBody(size))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Len(t, body, size)
+		require.Equal(t, "abcdef", string(body[:6]))
+	})
+
+	t.Run("get max concurrent calls to a scenario", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		const concurrency = 3
+
+		var arrived sync.WaitGroup
+		arrived.Add(concurrency)
+
+		barrier := func(t *testing.T, r *http.Request) {
+			arrived.Done()
+			arrived.Wait()
+		}
+
+		scenario := ms.Get("/get", barrier).Times(concurrency).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := http.Get(ms.URL() + "/get")
+				require.NoError(t, err)
+			}()
+		}
+
+		wg.Wait()
+
+		require.Equal(t, concurrency, scenario.MaxConcurrentCalls())
+	})
+
+	t.Run("mock request with path matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.GetMatching(regexp.MustCompile(`^/users/[0-9]+$`), MatchPath("/users/1")).
+			Times(2).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/users/1")
+		require.NoError(t, err)
+
+		require.False(t, mockT.Failed())
+
+		_, err = http.Get(ms.URL() + "/users/2")
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("reject handler response outside mock server", func(t *testing.T) {
+		responder := HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		require.PanicsWithValue(t, "mockhttp: HandlerResponse can only respond to requests received by a MockServer", func() {
+			responder(httptest.NewRecorder())
+		})
+	})
+
+	t.Run("mock request with handler response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(
+			ResponseHeaders(http.Header{"X-Foo": []string{"bar"}}),
+			HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte(r.URL.Query().Get("name")))
+			})),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get?name=foundation")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusAccepted, response.StatusCode)
+		require.Equal(t, "bar", response.Header.Get("X-Foo"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, "foundation", string(body))
+		require.Equal(t, int64(len("foundation")), ms.BytesServed())
+	})
+
+	t.Run("stop assertion at first failure", func(t *testing.T) {
+		testCases := []struct {
+			mode     FailureMode
+			failures int
+		}{
+			{mode: AllFailures, failures: 2},
+			{mode: FirstFailure, failures: 1},
+		}
+
+		for _, tc := range testCases {
+			ms := NewMockServer(WithPort(60000), WithFailureMode(tc.mode))
+
+			ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+			ms.Post("/post").Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(new(testing.T))
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.assertExpectations(counter)
+
+			require.Equal(t, tc.failures, counter.failures)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("list received requests when endpoint called wrong number of times", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(new(testing.T))
+		defer ms.Teardown()
+
+		for _, query := range []string{"?page=1", "?page=2"} {
+			_, err := http.Get(ms.URL() + "/get" + query)
+			require.NoError(t, err)
+		}
+
+		counter := &failureCounter{TB: new(testing.T)}
+		ms.assertExpectations(counter)
+
+		require.Equal(t, 1, counter.failures)
+		require.Contains(t, counter.messages[0], "GET /get?page=1, GET /get?page=2")
+	})
+
+	t.Run("add global headers to every response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithGlobalHeaders(http.Header{
+			"X-Mock":        []string{"true"},
+			"Cache-Control": []string{"no-store"},
+		}))
+
+		ms.Get("/default").Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Get("/override").Respond(ResponseHeaders(http.Header{"Cache-Control": []string{"max-age=60"}}))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		expected := map[string]string{"/default": "no-store", "/override": "max-age=60"}
+		for path, cacheControl := range expected {
+			response, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+
+			require.Equal(t, "true", response.Header.Get("X-Mock"))
+			require.Equal(t, []string{cacheControl}, response.Header.Values("Cache-Control"))
+		}
+	})
+
+	t.Run("respond to over-called endpoint with overflow response", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithOverflowResponse(ResponseStatusCode(http.StatusTooManyRequests)))
+
+		ms.Get("/get").Times(2).Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		expected := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}
+		for i, status := range expected {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equalf(t, status, response.StatusCode, "request %d was wrong", i)
+		}
+
+		counter := &failureCounter{TB: new(testing.T)}
+		ms.assertExpectations(counter)
+
+		require.Equal(t, 1, counter.failures)
+	})
+
+	t.Run("fail random requests reproducibly with seeded chaos", func(t *testing.T) {
+		statuses := func() []int {
+			ms := NewMockServer(
+				WithPort(60000),
+				WithSeed(42),
+				WithChaos(0.5, ResponseStatusCode(http.StatusServiceUnavailable)),
+			)
+
+			ms.Get("/get").Times(20).Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(t)
+			defer ms.Teardown()
+
+			var received []int
+			for i := 0; i < 20; i++ {
+				response, err := http.Get(ms.URL() + "/get")
+				require.NoError(t, err)
+
+				received = append(received, response.StatusCode)
+			}
+
+			return received
+		}
+
+		first := statuses()
+		require.Contains(t, first, http.StatusOK)
+		require.Contains(t, first, http.StatusServiceUnavailable)
+
+		require.Equal(t, first, statuses())
+	})
+
+	t.Run("summarize matcher failures", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchRawQuery("page=1")).Times(2).Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Get("/other").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(new(testing.T))
+		defer ms.Teardown()
+
+		for _, path := range []string{"/get?page=1", "/get?page=2", "/other"} {
+			_, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+		}
+
+		counter := &failureCounter{TB: new(testing.T)}
+		ms.AssertNoMatcherFailures(counter)
+
+		require.Equal(t, 1, counter.failures)
+		require.Contains(t, counter.messages[0], "endpoint GET /get scenario 1 failed matchers on 1 of 2 requests")
+		require.Contains(t, counter.messages[0], `unexpected raw query: got "page=2", expected "page=1"`)
+		require.NotContains(t, counter.messages[0], "/other")
+	})
+
+	t.Run("mock request with non empty body matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/post", MatchNonEmptyBody(), MatchJSONBody(`{"name":"a"}`)).
+			Times(2).
+			Respond(ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Post(ms.URL()+"/post", "application/json", strings.NewReader(`{"name":"a"}`))
+		require.NoError(t, err)
+
+		require.False(t, mockT.Failed())
+
+		_, err = http.Post(ms.URL()+"/post", "application/json", http.NoBody)
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with body hash matcher", func(t *testing.T) {
+		// larger than the buffers of the server and of io.Copy, so it is hashed in chunks.
+		content := bytes.Repeat([]byte("mockhttp"), 1<<19)
+		sum := sha256.Sum256(content)
+		digest := hex.EncodeToString(sum[:])
+
+		testCases := []struct {
+			algo   string
+			digest string
+			body   []byte
+			failed bool
+		}{
+			{algo: "sha256", digest: digest, body: content, failed: false},
+			{algo: "sha256", digest: strings.ToUpper(digest), body: content, failed: false},
+			{algo: "sha256", digest: digest, body: content[1:], failed: true},
+			{algo: "crc32", digest: digest, body: content, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Put("/upload", MatchNonEmptyBody(), MatchBodyHash(tc.algo, tc.digest)).
+				Respond(ResponseStatusCode(http.StatusNoContent))
+			ms.Put("/stream", MatchBodyHash(tc.algo, tc.digest)).
+				Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodPut, ms.URL()+"/upload", bytes.NewReader(tc.body))
+			require.NoError(t, err)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			// hides the length so the body is streamed chunked
+			request, err = http.NewRequest(http.MethodPut, ms.URL()+"/stream", struct{ io.Reader }{bytes.NewReader(tc.body)})
+			require.NoError(t, err)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "%s digest %s", tc.algo, tc.digest)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with multipart file content matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/upload", MatchMultipartFileContent("file", []byte("hello"))).
+			Respond(ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+
+		for name, content := range map[string]string{"a.txt": "hello", "b.txt": "world"} {
+			part, err := writer.CreateFormFile("file", name)
+			require.NoError(t, err)
+
+			_, err = part.Write([]byte(content))
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, writer.Close())
+
+		response, err := http.Post(ms.URL()+"/upload", writer.FormDataContentType(), body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with multipart form matcher", func(t *testing.T) {
+		report := []byte("isbn,title\n9780345317988,Foundation\n")
+		sum := sha256.Sum256([]byte("cover"))
+
+		matcher := MatchMultipartForm(
+			url.Values{"title": {"Foundation"}},
+			map[string][]MultipartFile{
+				"report": {{Filename: "report.csv", ContentType: "text/csv", Content: report}},
+				"cover":  {{Filename: "cover.png", SHA256: hex.EncodeToString(sum[:])}},
+			},
+		)
+
+		testCases := []struct {
+			title  string
+			cover  string
+			failed bool
+		}{
+			{title: "Foundation", cover: "cover", failed: false},
+			{title: "Dune", cover: "cover", failed: true},
+			{title: "Foundation", cover: "other cover", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/upload", matcher).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			body := new(bytes.Buffer)
+			writer := multipart.NewWriter(body)
+
+			require.NoError(t, writer.WriteField("title", tc.title))
+			require.NoError(t, writer.WriteField("subtitle", "ignored"))
+
+			part, err := writer.CreatePart(textproto.MIMEHeader{
+				"Content-Disposition": {`form-data; name="report"; filename="report.csv"`},
+				"Content-Type":        {"text/csv"},
+			})
+			require.NoError(t, err)
+
+			_, err = part.Write(report)
+			require.NoError(t, err)
+
+			part, err = writer.CreateFormFile("cover", "cover.png")
+			require.NoError(t, err)
+
+			_, err = part.Write([]byte(tc.cover))
+			require.NoError(t, err)
+
+			require.NoError(t, writer.Close())
+
+			_, err = http.Post(ms.URL()+"/upload", writer.FormDataContentType(), body)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "title %s cover %s", tc.title, tc.cover)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with multipart file count matcher", func(t *testing.T) {
+		testCases := []struct {
+			files  int
+			failed bool
+		}{
+			{files: 3, failed: false},
+			{files: 2, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/upload", MatchMultipartFileCount("file", 3), MatchMultipartFileCount("other", 0)).
+				Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			body := new(bytes.Buffer)
+			writer := multipart.NewWriter(body)
+
+			for i := 0; i < tc.files; i++ {
+				part, err := writer.CreateFormFile("file", fmt.Sprintf("%d.txt", i))
+				require.NoError(t, err)
+
+				_, err = part.Write([]byte("content"))
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, writer.Close())
+
+			response, err := http.Post(ms.URL()+"/upload", writer.FormDataContentType(), body)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusCreated, response.StatusCode)
+			require.Equalf(t, tc.failed, mockT.Failed(), "%d files", tc.files)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with json patch matchers", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		patch := `[{"op": "replace", "path": "/title", "value": "Foundation"}, {"op": "remove", "path": "/isbn"}]`
+		ms.Patch("/patch", MatchJSONPatch(patch)).Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Patch("/merge", MatchJSONMergePatch(`{"title": "Foundation", "isbn": null}`)).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		bodies := map[string]string{
+			"/patch": `[{"path": "/title", "op": "replace", "value": "Foundation"}, {"op": "remove", "path": "/isbn"}]`,
+			"/merge": `{"isbn": null, "title": "Foundation"}`,
+		}
+
+		for path, body := range bodies {
+			request, err := http.NewRequest(http.MethodPatch, ms.URL()+path, strings.NewReader(body))
+			require.NoError(t, err)
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusNoContent, response.StatusCode)
+		}
+	})
+
+	t.Run("verifies endpoints were called in sequence", func(t *testing.T) {
+		testCases := []struct {
+			paths  []string
+			failed bool
+		}{
+			{paths: []string{"/login", "/books", "/logout"}, failed: false},
+			{paths: []string{"/logout", "/books", "/login"}, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/login").Respond(ResponseStatusCode(http.StatusNoContent))
+			ms.Get("/books").Respond(ResponseStatusCode(http.StatusOK))
+			ms.Post("/logout").Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.ExpectSequence("POST /login", "POST /logout")
+
+			ms.Start(mockT)
+
+			for _, path := range tc.paths {
+				method := http.MethodPost
+				if path == "/books" {
+					method = http.MethodGet
+				}
+
+				request, err := http.NewRequest(method, ms.URL()+path, http.NoBody)
+				require.NoError(t, err)
+
+				_, err = http.DefaultClient.Do(request)
+				require.NoError(t, err)
+			}
+
+			ms.AssertExpectations()
+			require.Equalf(t, tc.failed, mockT.Failed(), "paths %v", tc.paths)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("verifies scripted conversation across endpoints", func(t *testing.T) {
+		testCases := []struct {
+			calls   []string
+			failure string
+		}{
+			{calls: []string{"POST /handshake", "GET /health", "POST /auth", "GET /data", "GET /data"}},
+			{
+				calls:   []string{"POST /auth", "POST /handshake", "GET /data", "GET /data"},
+				failure: "script deviated at step 1: expected POST /handshake, got POST /auth",
+			},
+			{
+				calls:   []string{"POST /handshake", "POST /auth", "GET /data"},
+				failure: "script stopped at step 4, expected GET /data to be called",
+			},
+		}
+
+		for _, tc := range testCases {
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/health").Respond(ResponseStatusCode(http.StatusOK))
+
+			script := ms.Script()
+			script.Step(http.MethodPost, "/handshake").Respond(ResponseStatusCode(http.StatusNoContent))
+			script.Step(http.MethodPost, "/auth").Respond(ResponseStatusCode(http.StatusNoContent))
+			script.Step(http.MethodGet, "/data").Respond(StringResponseBody("first"))
+			script.Step(http.MethodGet, "/data").Respond(StringResponseBody("second"))
+
+			ms.Start(new(testing.T))
+
+			var bodies []string
+			for _, call := range tc.calls {
+				method, path, _ := strings.Cut(call, " ")
+
+				request, err := http.NewRequest(method, ms.URL()+path, http.NoBody)
+				require.NoError(t, err)
+
+				response, err := http.DefaultClient.Do(request)
+				require.NoError(t, err)
+
+				if path == "/data" {
+					body, err := io.ReadAll(response.Body)
+					require.NoError(t, err)
+
+					bodies = append(bodies, string(body))
+				}
+			}
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.assertExpectations(counter)
+
+			if tc.failure == "" {
+				require.Zerof(t, counter.failures, "calls %v: %v", tc.calls, counter.messages)
+				require.Equal(t, []string{"first", "second"}, bodies)
+			} else {
+				require.Containsf(t, counter.messages, tc.failure, "calls %v", tc.calls)
+			}
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("share state between endpoints", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/books").Respond(
+			HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				ms.SetState("book", string(body))
+				w.WriteHeader(http.StatusCreated)
+			})),
+		)
+		ms.Get("/books").Respond(
+			HandlerResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				book, _ := ms.GetState("book")
+				_, _ = w.Write([]byte(book.(string)))
+			})),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		created, err := http.Post(ms.URL()+"/books", "application/json", strings.NewReader(`{"title": "Foundation"}`))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, created.StatusCode)
+
+		response, err := http.Get(ms.URL() + "/books")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.JSONEq(t, `{"title": "Foundation"}`, string(body))
+	})
+
+	t.Run("mock create-then-read resource", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Resource("/books")
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		created, err := http.Post(ms.URL()+"/books", "application/json", strings.NewReader(`{"title": "Foundation"}`))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, created.StatusCode)
+		require.Equal(t, "/books/1", created.Header.Get("Location"))
+		require.Equal(t, "application/json", created.Header.Get("Content-Type"))
+
+		response, err := http.Get(ms.URL() + created.Header.Get("Location"))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.JSONEq(t, `{"title": "Foundation"}`, string(body))
+
+		untyped, err := http.Post(ms.URL()+"/books", "", strings.NewReader("Foundation"))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, untyped.StatusCode)
+		require.Equal(t, "text/plain; charset=utf-8", untyped.Header.Get("Content-Type"))
+
+		untypedRead, err := http.Get(ms.URL() + untyped.Header.Get("Location"))
+		require.NoError(t, err)
+
+		require.Equal(t, "text/plain; charset=utf-8", untypedRead.Header.Get("Content-Type"))
+
+		missing, err := http.Get(ms.URL() + "/books/3")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, missing.StatusCode)
+	})
+
+	t.Run("fail alpn matcher without tls", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchALPN("h2")).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with alpn matcher over tls", func(t *testing.T) {
+		testCases := []struct {
+			proto        string
+			forceHTTP1   bool
+			expectedALPN string
+			failed       bool
+		}{
+			{proto: "HTTP/2.0", expectedALPN: "h2"},
+			{proto: "HTTP/1.1", forceHTTP1: true, expectedALPN: "http/1.1"},
+			{proto: "HTTP/1.1", forceHTTP1: true, expectedALPN: "h2", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000), WithTLS())
+
+			ms.Get("/get", MatchALPN(tc.expectedALPN)).Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			client := ms.Client()
+			if tc.forceHTTP1 {
+				transport := client.Transport.(*http.Transport)
+				transport.ForceAttemptHTTP2 = false
+				transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+				transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			}
+
+			require.True(t, strings.HasPrefix(ms.URL(), "https://"))
+
+			response, err := client.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.proto, response.Proto)
+			require.Equal(t, tc.failed, mockT.Failed(), tc.expectedALPN)
+
+			client.CloseIdleConnections()
+			ms.Teardown()
+		}
+	})
+
+	t.Run("isolate response plan per test", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithPerTestIsolation())
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusAccepted))
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for _, name := range []string{"first", "second"} {
+			t.Run(name, func(t *testing.T) {
+				expected := []int{http.StatusAccepted, http.StatusOK}
+				for _, code := range expected {
+					request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+					require.NoError(t, err)
+
+					TagRequest(request, t)
+
+					response, err := http.DefaultClient.Do(request)
+					require.NoError(t, err)
+
+					require.Equal(t, code, response.StatusCode)
+				}
+			})
+		}
+	})
+
+	t.Run("get snapshot of expectations", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchQueryParams(url.Values{"foo": []string{"bar"}})).
+			Times(2).
+			Respond(JSONResponseBody(`{"result": true}`), ResponseStatusCode(http.StatusOK))
+		ms.Delete("/delete").Meta("owner", "books-team").Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Post(
+			"/post",
+			MatchHeaderPresent("X-Request-Id"),
+			MatchContentTypeIn("application/json", "text/plain"),
+			MatchTimeHeader("Date", time.Minute),
+			NewDescribedMatcher(`MatchTenant("acme")`, func(t testing.TB, r *http.Request) {}),
+			func(t *testing.T, r *http.Request) {},
+		).Respond(
+			ResponseHeaders(http.Header{"X-Version": {"1"}}),
+			NewDescribedResponder(`TenantResponse("acme")`, func(w http.ResponseWriter) {}),
+			noop,
+		)
+
+		expected := []Expectation{
+			{
+				Endpoint:   "DELETE /delete",
+				Method:     http.MethodDelete,
+				Path:       "/delete",
+				Times:      1,
+				Matchers:   []string{},
+				Responders: []string{"ResponseStatusCode(204)"},
+				Meta:       map[string]any{"owner": "books-team"},
+			},
+			{
+				Endpoint:   "GET /get",
+				Method:     http.MethodGet,
+				Path:       "/get",
+				Times:      2,
+				Matchers:   []string{`MatchQueryParams(url.Values{"foo":[]string{"bar"}})`},
+				Responders: []string{`JSONResponseBody("{\"result\": true}")`, "ResponseStatusCode(200)"},
+			},
+			{
+				Endpoint: "POST /post",
+				Method:   http.MethodPost,
+				Path:     "/post",
+				Times:    1,
+				Matchers: []string{
+					`MatchHeaderPresent("X-Request-Id")`,
+					`MatchContentTypeIn("application/json", "text/plain")`,
+					`MatchTimeHeader("Date", 1m0s)`,
+					`MatchTenant("acme")`,
+					"TestMockServer",
+				},
+				Responders: []string{
+					`ResponseHeaders(http.Header{"X-Version":[]string{"1"}})`,
+					`TenantResponse("acme")`,
+					"noop",
+				},
+			},
+		}
+
+		require.Equal(t, expected, ms.Expectations())
+	})
+
+	t.Run("verifies scenario request bodies in order", func(t *testing.T) {
+		testCases := []struct {
+			bodies   []string
+			failures []string
+		}{
+			{bodies: []string{`{"cursor": null}`, `{ "cursor":"b" }`, "done"}},
+			{
+				bodies: []string{`{"cursor": null}`, `{"cursor": "c"}`, "done"},
+				failures: []string{
+					`endpoint POST /search call 2 has unexpected body: got {"cursor": "c"}, expected {"cursor": "b"}`,
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/search").
+				Times(3).
+				AssertBodiesInOrder(`{"cursor": null}`, `{"cursor": "b"}`, "done").
+				Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(new(testing.T))
+
+			for _, body := range tc.bodies {
+				_, err := http.Post(ms.URL()+"/search", "application/json", strings.NewReader(body))
+				require.NoError(t, err)
+			}
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.assertExpectations(counter)
+
+			require.Equalf(t, tc.failures, counter.messages, "bodies %v", tc.bodies)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("report scenario metadata on failures", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").
+			Meta("owner", "books-team").
+			Meta("ticket", 1234).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(new(testing.T))
+		defer ms.Teardown()
+
+		counter := &failureCounter{TB: new(testing.T)}
+		ms.assertExpectations(counter)
+
+		require.Equal(t, []string{"endpoint GET /get was not called [owner=books-team ticket=1234]"}, counter.messages)
+	})
+
+	t.Run("send 100 continue before reading body", func(t *testing.T) {
+		testCases := []struct {
+			options   []Option
+			continued bool
+			body      []byte
+		}{
+			{options: []Option{WithPort(60000), WithExpectContinue()}, continued: true, body: []byte("content")},
+			{options: []Option{WithPort(60000)}, continued: false},
+		}
+
+		for _, tc := range testCases {
+			ms := NewMockServer(tc.options...)
+
+			ms.Put("/upload").Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(t)
+
+			var continued bool
+			trace := &httptrace.ClientTrace{
+				Got100Continue: func() { continued = true },
+			}
+
+			request, err := http.NewRequestWithContext(
+				httptrace.WithClientTrace(context.Background(), trace),
+				http.MethodPut,
+				ms.URL()+"/upload",
+				strings.NewReader("content"),
+			)
+			require.NoError(t, err)
+
+			request.Header.Set("Expect", "100-continue")
+
+			client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+
+			response, err := client.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusNoContent, response.StatusCode)
+			require.Equal(t, tc.continued, continued)
+			require.Equal(t, tc.body, ms.ReceivedRequests()[0].Body)
+
+			client.CloseIdleConnections()
+			ms.Teardown()
+		}
+	})
+
+	t.Run("gzip responses when client accepts it", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithAutoGzip())
+
+		ms.Get("/get").Times(2).Respond(JSONResponseBody(`{"result": true}`))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for accepted, encoding := range map[string]string{"gzip": "gzip", "identity": ""} {
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("Accept-Encoding", accepted)
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, encoding, response.Header.Get("Content-Encoding"))
+
+			reader := response.Body
+			if encoding == "gzip" {
+				reader, err = gzip.NewReader(response.Body)
+				require.NoError(t, err)
+			}
+
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+
+			require.JSONEq(t, `{"result": true}`, string(body))
+		}
+	})
+
+	t.Run("compress scenario response with negotiated encoding", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").
+			Times(4).
+			WithCompression("br", "gzip", "deflate").
+			Respond(JSONResponseBody(`{"result": true}`))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		testCases := map[string]string{
+			"gzip, deflate, br":      "br",
+			"gzip, br;q=0.5":         "gzip",
+			"deflate, identity;q=0":  "deflate",
+			"identity, compress;q=1": "",
+		}
+
+		for accepted, encoding := range testCases {
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("Accept-Encoding", accepted)
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, encoding, response.Header.Get("Content-Encoding"), "accepted %s", accepted)
+			require.Equal(t, "Accept-Encoding", response.Header.Get("Vary"))
+
+			var reader io.Reader = response.Body
+			switch encoding {
+			case "br":
+				reader = brotli.NewReader(response.Body)
+			case "gzip":
+				reader, err = gzip.NewReader(response.Body)
+			case "deflate":
+				reader, err = zlib.NewReader(response.Body)
+			}
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+
+			require.JSONEq(t, `{"result": true}`, string(body))
+		}
+	})
+
+	t.Run("restart mock server at same port with port reuse", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			ms := NewMockServer(WithPort(60000), WithReusePort())
+
+			ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(t)
+
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("reject running mock server at same port with port reuse", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithReusePort())
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+
+		// Fatal stops the goroutine calling it.
+		mockT := new(testing.T)
+		other := NewMockServer(WithPort(60000), WithReusePort())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			other.Start(mockT)
+		}()
+		<-done
+
+		require.True(t, mockT.Failed())
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+		ms.Teardown()
+
+		other = NewMockServer(WithPort(60000), WithReusePort())
+		other.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		other.Start(t)
+		defer other.Teardown()
+
+		response, err = http.Get(other.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("mock request with header count matcher", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchHeaderCount("Via", 2), MatchHeaderCount("X-Missing", 0)).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+		require.NoError(t, err)
+
+		request.Header.Add("Via", "1.1 proxy-a")
+		request.Header.Add("Via", "1.1 proxy-b")
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("teardown mock server when context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.StartWithContext(ctx, t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+		cancel()
+
+		require.Eventually(t, func() bool {
+			_, err := net.Dial("tcp", "localhost:60000")
+			return err != nil
+		}, 2*time.Second, 200*time.Millisecond)
+	})
+
+	t.Run("teardown restarted mock server", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Times(2).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		for i := 0; i < 2; i++ {
+			ms.Start(t)
+
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusNoContent, response.StatusCode)
+
+			ms.Teardown()
+
+			_, err = net.Dial("tcp", "localhost:60000")
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("detect response bodies abandoned by the client", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithBodyLeakDetection())
+
+		ms.Get("/get").Times(3).Respond(StringResponseBody("hello"))
+
+		client := ms.Client()
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		read, err := client.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(read.Body)
+		require.NoError(t, err)
+		require.NoError(t, read.Body.Close())
+
+		unread, err := client.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+		require.NoError(t, unread.Body.Close())
+
+		_, err = client.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		leaks := make([]string, 0, len(ms.trackedBodies))
+		for _, body := range ms.trackedBodies {
+			leaks = append(leaks, body.leaked())
+		}
+
+		require.Equal(t, []string{"", "was closed before being fully read", "was not closed"}, leaks)
+	})
+
+	t.Run("detect response bodies abandoned by any client", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithBodyLeakDetection())
+
+		ms.Get("/large").Times(2).Respond(StringResponseBody(strings.Repeat("mockhttp", 4<<20)))
+
+		ms.Start(t)
+
+		closing := &http.Client{Transport: &http.Transport{}}
+
+		closed, err := closing.Get(ms.URL() + "/large?client=closing")
+		require.NoError(t, err)
+		require.NoError(t, closed.Body.Close())
+
+		stalling := &http.Client{Transport: &http.Transport{}}
+
+		_, err = stalling.Get(ms.URL() + "/large?client=stalling")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return len(ms.tracked.abandonedBodies()) == 2
+		}, 2*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, []string{
+			"response body of GET /large?client=closing was closed before being fully read",
+			"response body of GET /large?client=stalling was not read",
+		}, ms.tracked.abandonedBodies())
+	})
+
+	t.Run("mock request with jwt claim matcher", func(t *testing.T) {
+		secret := []byte("secret")
+
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","scope":["read","write"]}`))
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(header + "." + claims))
+		token := header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+		testCases := []struct {
+			secret []byte
+			failed bool
+		}{
+			{secret: secret, failed: false},
+			{secret: []byte("wrong"), failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get(
+				"/get",
+				MatchJWTClaim(tc.secret, "sub", "user-1"),
+				MatchJWTClaim(tc.secret, "scope", []string{"read", "write"}),
+			).Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.failed, mockT.Failed())
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("use matchers as request matchers", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/orders?page=2", http.NoBody)
+		request.Header.Set("X-App", "shop")
+
+		var matcher RequestMatcher = MatchQueryParam("page", "2")
+		require.True(t, matcher.Match(request))
+		require.Empty(t, matcher.Diff(request))
+
+		matcher = MatchHeader(http.Header{"X-App": {"admin"}})
+		require.False(t, matcher.Match(request))
+		require.Contains(t, matcher.Diff(request), "admin")
+
+		custom := AsMatcher(MatchQueryParams2(url.Values{"page": {"3"}}))
+		require.False(t, custom.Match(request))
+
+		require.Contains(t, custom.Diff(request), `"page"`)
+	})
+
+	t.Run("report closest scenario when request matches none", func(t *testing.T) {
+		testCases := []struct {
+			option bool
+			query  string
+			app    string
+			report string
+		}{
+			{option: true, query: "?item=pen", app: "shop", report: "request matches scenario 2, but call order dispatched it to scenario 1"},
+			{option: true, query: "?item=pen", app: "admin", report: "request matches no scenario, the closest is scenario 2: "},
+			{option: true, query: "?item=cup", app: "shop"},
+			{option: false, query: "?item=pen", app: "shop"},
+		}
+
+		for _, tc := range testCases {
+			var opts []Option
+			if tc.option {
+				opts = append(opts, WithClosestScenarioReport())
+			}
+
+			ms := NewMockServer(append(opts, WithPort(60000))...)
+
+			var plainCalls int64
+			plain := func(t *testing.T, r *http.Request) {
+				atomic.AddInt64(&plainCalls, 1)
+			}
+
+			tempDir := NewMatcher(func(t testing.TB, r *http.Request) {
+				require.DirExists(t, t.TempDir())
+			})
+
+			ms.Post("/orders", MatchQueryParam("item", "book"), MatchHeader(http.Header{"X-App": {"shop"}})).
+				Respond(ResponseStatusCode(http.StatusCreated))
+			ms.Post("/orders", MatchQueryParam("item", "pen"), MatchHeader(http.Header{"X-App": {"shop"}}), plain, tempDir).
+				Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(new(testing.T))
+
+			request, err := http.NewRequest(http.MethodPost, ms.URL()+"/orders"+tc.query, strings.NewReader(`{}`))
+			require.NoError(t, err)
+
+			request.Header.Set("X-App", tc.app)
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusCreated, response.StatusCode)
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.AssertNoMatcherFailures(counter)
+
+			require.Len(t, counter.messages, 1)
+			if tc.report == "" {
+				require.NotContains(t, counter.messages[0], "endpoint POST /orders request matches")
+			} else {
+				require.Contains(t, counter.messages[0], tc.report)
+			}
+
+			require.Zero(t, atomic.LoadInt64(&plainCalls))
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("stop matcher at first fatal failure", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		plain := func(t *testing.T, r *http.Request) {
+			require.NotEmpty(t, r.Header.Get("X-App"))
+			_ = map[string]any{}["app"].(string)
+		}
+
+		recorded := NewMatcher(func(t testing.TB, r *http.Request) {
+			require.NotEmpty(t, r.Header.Get("X-Tenant"))
+			_ = map[string]any{}["tenant"].(string)
+		})
+
+		scenario := ms.Get("/get", plain, recorded).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+		require.True(t, mockT.Failed())
+		require.False(t, scenario.AllMatched())
+	})
+
+	t.Run("mock request with user agent matchers", func(t *testing.T) {
+		testCases := []struct {
+			matcher   Matcher
+			userAgent string
+			failed    bool
+		}{
+			{matcher: MatchUserAgent("books-sdk/1.4.2 (linux)"), userAgent: "books-sdk/1.4.2 (linux)"},
+			{matcher: MatchUserAgent("books-sdk/1.4.2 (linux)"), userAgent: "books-sdk/1.4.3 (linux)", failed: true},
+			{matcher: MatchUserAgentRegex(`^books-sdk/\d+\.\d+\.\d+ `), userAgent: "books-sdk/1.4.2 (darwin)"},
+			{matcher: MatchUserAgentRegex(`^books-sdk/\d+\.\d+\.\d+ `), userAgent: "Go-http-client/1.1", failed: true},
+			{matcher: MatchUserAgentRegex(`^books-sdk/`), userAgent: "", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/books", tc.matcher).Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/books", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("User-Agent", tc.userAgent)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "user agent %q", tc.userAgent)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with host matcher", func(t *testing.T) {
+		testCases := []struct {
+			expected string
+			host     string
+			failed   bool
+		}{
+			{expected: "books.example.com", host: "books.example.com"},
+			{expected: "books.example.com", host: "Books.Example.com:8080"},
+			{expected: "books.example.com:8080", host: "books.example.com:8080"},
+			{expected: "books.example.com:8080", host: "books.example.com:9090", failed: true},
+			{expected: "books.example.com", host: "authors.example.com", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/books", MatchHost(tc.expected)).Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/books", http.NoBody)
+			require.NoError(t, err)
+
+			request.Host = tc.host
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "expected %q host %q", tc.expected, tc.host)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with content type matcher", func(t *testing.T) {
+		testCases := []struct {
+			expected    string
+			contentType string
+			failed      bool
+		}{
+			{expected: "application/json", contentType: "application/json"},
+			{expected: "application/json", contentType: "application/json; charset=utf-8"},
+			{expected: "application/json", contentType: "Application/JSON"},
+			{expected: "application/json", contentType: "text/plain", failed: true},
+			{expected: "application/json", contentType: "", failed: true},
+			{expected: "text/plain; charset=utf-8", contentType: "text/plain; charset=UTF-8; format=flowed"},
+			{expected: "text/plain; charset=utf-8", contentType: "text/plain", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/orders", MatchContentType(tc.expected)).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodPost, ms.URL()+"/orders", strings.NewReader(`{}`))
+			require.NoError(t, err)
+
+			if tc.contentType != "" {
+				request.Header.Set("Content-Type", tc.contentType)
+			}
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "expected %q content type %q", tc.expected, tc.contentType)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with bearer token and jwt claims matchers", func(t *testing.T) {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","aud":"orders","iat":1700000000}`))
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(header + "." + claims))
+		token := header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+		testCases := []struct {
+			name     string
+			matchers []Matcher
+			failed   bool
+		}{
+			{name: "token", matchers: []Matcher{MatchBearerToken(token)}},
+			{name: "other token", matchers: []Matcher{MatchBearerToken("other")}, failed: true},
+			{name: "unverified claims", matchers: []Matcher{MatchJWTClaims(nil, map[string]any{"sub": "user-1", "aud": "orders"})}},
+			{name: "verified claims", matchers: []Matcher{MatchJWTClaims([]byte("secret"), map[string]any{"iat": 1700000000})}},
+			{name: "wrong key", matchers: []Matcher{MatchJWTClaims([]byte("wrong"), map[string]any{"sub": "user-1"})}, failed: true},
+			{name: "wrong claim", matchers: []Matcher{MatchJWTClaims(nil, map[string]any{"aud": "billing"})}, failed: true},
+			{name: "missing claim", matchers: []Matcher{MatchJWTClaims(nil, map[string]any{"scope": "read"})}, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/orders", tc.matchers...).Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/orders", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.failed, mockT.Failed(), tc.name)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with fluent interaction", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Expect("post", "/orders").
+			WithJSONBody(`{"item": "book"}`).
+			WithHeader("X-App", "foo").
+			Times(2).
+			Respond().
+			Status(http.StatusCreated).
+			Header("X-Order", "1").
+			JSON(`{"id": 1}`)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for i := 0; i < 2; i++ {
+			request, err := http.NewRequest(http.MethodPost, ms.URL()+"/orders", strings.NewReader(`{"item": "book"}`))
+			require.NoError(t, err)
+
+			request.Header.Set("X-App", "foo")
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusCreated, response.StatusCode)
+			require.Equal(t, "1", response.Header.Get("X-Order"))
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			require.JSONEq(t, `{"id": 1}`, string(body))
+		}
+	})
+
+	t.Run("start mock server on ipv6", func(t *testing.T) {
+		probe, err := net.Listen("tcp", "[::1]:0")
+		if err != nil {
+			t.Skipf("ipv6 loopback is not available: %s", err.Error())
+		}
+		probe.Close()
+
+		ms := NewMockServer(WithPort(60000), WithNetwork("tcp6"))
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		require.Equal(t, "http://[::1]:60000", ms.URL())
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("get total requests received by mock server", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Times(2).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(new(testing.T))
+		defer ms.Teardown()
+
+		for _, path := range []string{"/get", "/get", "/unmatched"} {
+			_, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, 3, ms.TotalRequests())
+	})
+
+	t.Run("mock request with inline query and headers", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get(
+			"/get",
+			MatchQueryParams(Query("tag", "go", "tag", "http", "page", "1")),
+			MatchHeader(Headers("x-app", "foo")),
+		).Respond(
+			ResponseStatusCode(http.StatusNoContent),
+			ResponseHeaders(Headers("X-Foo", "bar")),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get?tag=go&tag=http&page=1", http.NoBody)
+		require.NoError(t, err)
+
+		request.Header.Set("X-App", "foo")
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+		require.Equal(t, "bar", response.Header.Get("X-Foo"))
+		require.Panics(t, func() { Query("odd") })
+	})
+
+	t.Run("fail when responses served exactly are exhausted", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").RespondExactly(
+			[]Responder{ResponseStatusCode(http.StatusAccepted)},
+			[]Responder{ResponseStatusCode(http.StatusOK)},
+		)
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		expected := []int{http.StatusAccepted, http.StatusOK}
+		for _, code := range expected {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, code, response.StatusCode)
+		}
+
+		require.False(t, mockT.Failed())
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusInternalServerError, response.StatusCode)
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with raw body matchers", func(t *testing.T) {
+		csv := "isbn,title\n9780345317988,Foundation\n"
+
+		testCases := []struct {
+			body   string
+			failed bool
+		}{
+			{body: csv, failed: false},
+			{body: strings.TrimSuffix(csv, "\n"), failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/upload", MatchBody([]byte(csv)), MatchBodyString(csv)).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+"/upload", "text/csv", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "body %q", tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with basic auth matcher", func(t *testing.T) {
+		testCases := []struct {
+			authorization string
+			failed        bool
+			message       string
+		}{
+			{authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("aladdin:opensesame"))},
+			{authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("aladdin:wrong")), failed: true, message: "password"},
+			{authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("jafar:opensesame")), failed: true, message: "user"},
+			{authorization: "Basic not-base64", failed: true, message: "malformed"},
+			{authorization: "Bearer abc", failed: true, message: "malformed"},
+			{authorization: "", failed: true, message: "no Authorization header"},
+		}
+
+		for _, tc := range testCases {
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/account", MatchBasicAuth("aladdin", "opensesame")).
+				Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(new(testing.T))
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/account", nil)
+			require.NoError(t, err)
+
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			counter := &failureCounter{TB: new(testing.T)}
+			ms.AssertNoMatcherFailures(counter)
+
+			require.Equalf(t, tc.failed, counter.failures > 0, "authorization %q", tc.authorization)
+			if tc.failed {
+				require.Contains(t, counter.messages[0], tc.message)
+			}
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with query parameter subset matchers", func(t *testing.T) {
+		testCases := []struct {
+			query  string
+			failed bool
+		}{
+			{query: "?page=2&size=10"},
+			{query: "?page=2&size=10&trace_id=abc&utm_source=x"},
+			{query: "?page=3&size=10", failed: true},
+			{query: "?page=2", failed: true},
+			{query: "?page=2&size=10&size=20", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/orders", MatchQueryParam("page", "2"), MatchQueryParamsSubset(url.Values{"size": {"10"}})).
+				Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(mockT)
+
+			_, err := http.Get(ms.URL() + "/orders" + tc.query)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "query %q", tc.query)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with header presence matchers", func(t *testing.T) {
+		testCases := []struct {
+			headers http.Header
+			failed  bool
+		}{
+			{headers: http.Header{"Authorization": {"Bearer abc"}}},
+			{headers: http.Header{"Authorization": {""}}},
+			{headers: http.Header{}, failed: true},
+			{headers: http.Header{"Authorization": {"Bearer abc"}, "X-Api-Version": {"1"}}, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/orders", MatchHeaderPresent("Authorization"), MatchHeaderAbsent("X-Api-Version")).
+				Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/orders", nil)
+			require.NoError(t, err)
+
+			request.Header = tc.headers
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "headers %v", tc.headers)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with regex matchers", func(t *testing.T) {
+		const uuid = `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+
+		testCases := []struct {
+			query     string
+			requestID string
+			body      string
+			failed    bool
+		}{
+			{query: "?ts=1700000000", requestID: "0b7e2a3c-5c1d-4f7e-9a8b-1c2d3e4f5a6b", body: `{"signature":"abc123"}`},
+			{query: "?ts=yesterday", requestID: "0b7e2a3c-5c1d-4f7e-9a8b-1c2d3e4f5a6b", body: `{"signature":"abc123"}`, failed: true},
+			{query: "", requestID: "0b7e2a3c-5c1d-4f7e-9a8b-1c2d3e4f5a6b", body: `{"signature":"abc123"}`, failed: true},
+			{query: "?ts=1700000000", requestID: "42", body: `{"signature":"abc123"}`, failed: true},
+			{query: "?ts=1700000000", requestID: "0b7e2a3c-5c1d-4f7e-9a8b-1c2d3e4f5a6b", body: `{}`, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post(
+				"/events",
+				MatchQueryParamRegex("ts", `^[0-9]{10}$`),
+				MatchHeaderRegex("X-Request-Id", uuid),
+				MatchBodyRegex(`"signature":"[a-z0-9]+"`),
+			).Respond(ResponseStatusCode(http.StatusAccepted))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodPost, ms.URL()+"/events"+tc.query, strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			request.Header.Set("X-Request-Id", tc.requestID)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "query %q id %s body %s", tc.query, tc.requestID, tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with json path matcher", func(t *testing.T) {
+		body := `{"order": {"id": 7, "items": [{"sku": "A-1", "qty": 2}, {"sku": "B-2", "qty": 1}], "tags": {"gift wrap": true}}}`
+
+		testCases := []struct {
+			path     string
+			expected any
+			failed   bool
+		}{
+			{path: "$.order.items[0].sku", expected: "A-1", failed: false},
+			{path: "$.order.items[1]", expected: map[string]any{"qty": 1, "sku": "B-2"}, failed: false},
+			{path: "$.order.id", expected: 7, failed: false},
+			{path: "$['order'].tags['gift wrap']", expected: true, failed: false},
+			{path: "$.order.items[0].sku", expected: "B-2", failed: true},
+			{path: "$.order.items[2].sku", expected: "A-1", failed: true},
+			{path: "$.order.customer", expected: nil, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/orders", MatchJSONPath(tc.path, tc.expected)).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+"/orders", "application/json", strings.NewReader(body))
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "path %s", tc.path)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with form body matcher", func(t *testing.T) {
+		expected := url.Values{"grant_type": {"client_credentials"}, "scope": {"read", "write"}}
+
+		testCases := []struct {
+			contentType string
+			body        string
+			failed      bool
+		}{
+			{contentType: "application/x-www-form-urlencoded", body: "scope=read&grant_type=client_credentials&scope=write"},
+			{contentType: "application/x-www-form-urlencoded; charset=utf-8", body: expected.Encode()},
+			{contentType: "application/x-www-form-urlencoded", body: "grant_type=client_credentials&scope=read", failed: true},
+			{contentType: "text/plain", body: expected.Encode(), failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/token", MatchFormBody(expected)).Respond(ResponseStatusCode(http.StatusOK))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+"/token", tc.contentType, strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "%s body %s", tc.contentType, tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with xml body matcher", func(t *testing.T) {
+		expected := `<book isbn="9780345317988" lang="en"><title>Foundation</title><author>Asimov</author></book>`
+
+		testCases := []struct {
+			body   string
+			failed bool
+		}{
+			{body: expected, failed: false},
+			{
+				body: `<?xml version="1.0"?>
+<!-- formatted -->
+<book lang="en" isbn="9780345317988">
+	<title> Foundation </title>
+	<author>Asimov</author>
+</book>`,
+				failed: false,
+			},
+			{body: `<book isbn="9780345317988" lang="pt"><title>Foundation</title><author>Asimov</author></book>`, failed: true},
+			{body: `<book isbn="9780345317988" lang="en"><author>Asimov</author><title>Foundation</title></book>`, failed: true},
+			{body: `<book isbn="9780345317988" lang="en"><title>Foundation</title>`, failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/books", MatchXMLBody(expected)).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+"/books", "application/xml", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "body %s", tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with path params matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/users/{id}/books/{isbn}", MatchPathParams(map[string]string{"id": "42", "isbn": "9780345317988"})).
+			Times(2).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/users/42/books/9780345317988")
+		require.NoError(t, err)
+
+		require.False(t, mockT.Failed())
+
+		_, err = http.Get(ms.URL() + "/users/7/books/9780345317988")
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with raw query matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchRawQuery("b=2&a=hello%20world")).
+			Times(2).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get?b=2&a=hello%20world")
+		require.NoError(t, err)
+
+		require.False(t, mockT.Failed())
+
+		_, err = http.Get(ms.URL() + "/get?a=hello+world&b=2")
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with nd-json stream response", func(t *testing.T) {
+		for _, options := range [][]Option{{WithPort(60000)}, {WithPort(60000), WithAutoGzip()}} {
+			ms := NewMockServer(options...)
+
+			interval := 50 * time.Millisecond
+			items := []any{
+				map[string]any{"event": "started"},
+				map[string]any{"event": "progress", "percent": 50},
+				map[string]any{"event": "finished"},
+			}
+			ms.Get("/events").Respond(NDJSONResponseBody(items, interval))
+
+			ms.Start(t)
+
+			response, err := http.Get(ms.URL() + "/events")
+			require.NoError(t, err)
+
+			require.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+			require.Empty(t, response.Header.Get("Content-Length"))
+			require.False(t, response.Uncompressed)
+
+			reader := bufio.NewReader(response.Body)
+
+			first, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			require.JSONEq(t, `{"event": "started"}`, first)
+
+			// the first line arrives before the rest of the stream is written.
+			start := time.Now()
+
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+
+			require.GreaterOrEqual(t, time.Since(start), interval)
+			require.Equal(t, `{"event":"progress","percent":50}`+"\n"+`{"event":"finished"}`+"\n", string(rest))
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with route info response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/users/{id}/books/{isbn}").Respond(RouteInfoResponse())
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/users/42/books/9780345317988")
+		require.NoError(t, err)
+
+		var info RouteInfo
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&info))
+
+		expected := RouteInfo{
+			Pattern: "/users/{id}/books/{isbn}",
+			Method:  http.MethodGet,
+			Params:  map[string]string{"id": "42", "isbn": "9780345317988"},
+		}
+		require.Equal(t, expected, info)
+	})
+
+	t.Run("mock request with validated json file response body", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(JSONFileResponseBodyValidated(t, "./fixtures/body.json"))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		expected, err := os.ReadFile("./fixtures/body.json")
+		require.NoError(t, err)
+
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.JSONEq(t, string(expected), string(body))
+
+		malformed := filepath.Join(t.TempDir(), "malformed.json")
+		require.NoError(t, os.WriteFile(malformed, []byte(`{"title": "Foundation",}`), 0o600))
+
+		// Fatalf stops the goroutine calling it.
+		mockT := new(testing.T)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			JSONFileResponseBodyValidated(mockT, malformed)
+		}()
+		<-done
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with lazy json file response body", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		fixture := filepath.Join(t.TempDir(), "body.json")
+		require.NoError(t, os.WriteFile(fixture, []byte(`{"version": 1}`), 0o600))
+
+		ms.Get("/get").Times(3).Respond(JSONFileResponseBodyLazy(fixture), ResponseStatusCode(http.StatusOK))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		get := func() (int, string) {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			return response.StatusCode, string(body)
+		}
+
+		status, body := get()
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"version": 1}`, body)
+
+		require.NoError(t, os.WriteFile(fixture, []byte(`{"version": 2}`), 0o600))
+
+		status, body = get()
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"version": 2}`, body)
+
+		require.False(t, mockT.Failed())
+		require.NoError(t, os.Remove(fixture))
+
+		status, _ = get()
+		require.Equal(t, http.StatusInternalServerError, status)
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with json response shorthand", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/books").RespondJSON(http.StatusCreated, `{"isbn": "9780345317988"}`)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Post(ms.URL()+"/books", "application/json", strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.JSONEq(t, `{"isbn": "9780345317988"}`, string(body))
+	})
+
+	t.Run("mock request with respond func", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithGlobalHeaders(http.Header{"X-Mock": {"true"}}))
+
+		ms.Get("/users/{id}").Times(2).RespondFunc(func(r *http.Request) (int, http.Header, []byte, error) {
+			id := chi.URLParam(r, "id")
+			if id == "0" {
+				return 0, nil, nil, errors.New("user 0 is reserved")
+			}
+
+			headers := http.Header{"Content-Type": {"application/json"}}
+
+			return http.StatusAccepted, headers, []byte(`{"id":"` + id + `"}`), nil
+		})
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/users/42")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusAccepted, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.JSONEq(t, `{"id":"42"}`, string(body))
+		require.False(t, mockT.Failed())
+
+		response, err = http.Get(ms.URL() + "/users/0")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusInternalServerError, response.StatusCode)
+		require.Equal(t, "true", response.Header.Get("X-Mock"))
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("reject respond func combined with exact responses", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		respond := func(r *http.Request) (int, http.Header, []byte, error) {
+			return http.StatusOK, nil, nil, nil
+		}
+
+		require.Panics(t, func() {
+			ms.Get("/get").RespondExactly([]Responder{ResponseStatusCode(http.StatusOK)}).RespondFunc(respond)
+		})
+
+		require.Panics(t, func() {
+			ms.Get("/get").RespondFunc(respond).RespondExactly([]Responder{ResponseStatusCode(http.StatusOK)})
+		})
+	})
+
+	t.Run("mock request with dynamic responder", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/users/{id}").Respond(
+			DynamicResponder(func(r *http.Request, w http.ResponseWriter) {
+				w.Write([]byte(`{"id":"` + chi.URLParam(r, "id") + `"}`)) //nolint:errcheck // test helper
+			}),
+			ResponseStatusCode(http.StatusAccepted),
+			ResponseHeaders(http.Header{"Content-Type": []string{"application/json"}}),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/users/42")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusAccepted, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.JSONEq(t, `{"id":"42"}`, string(body))
+	})
+
+	t.Run("mock request with response computed from path parameter", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.GetParam("/users/{id:[0-9]+}", func(id string) []Responder {
+			if id == "0" {
+				return []Responder{ResponseStatusCode(http.StatusNotFound)}
+			}
+
+			return []Responder{JSONResponseBody(`{"id":` + id + `}`)}
+		}).Times(2)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/users/42")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.JSONEq(t, `{"id":42}`, string(body))
+
+		response, err = http.Get(ms.URL() + "/users/0")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("mock request with time header matcher", func(t *testing.T) {
+		now := time.Now().UTC()
+
+		testCases := []struct {
+			date   string
+			failed bool
+		}{
+			{date: now.Format(http.TimeFormat), failed: false},
+			{date: now.Format(time.RFC850), failed: false},
+			{date: now.Format(time.ANSIC), failed: false},
+			{date: now.Add(-time.Hour).Format(http.TimeFormat), failed: true},
+			{date: now.Add(time.Hour).Format(http.TimeFormat), failed: true},
+			{date: "yesterday", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Get("/get", MatchTimeHeader("Date", time.Minute)).Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			request, err := http.NewRequest(http.MethodGet, ms.URL()+"/get", http.NoBody)
+			require.NoError(t, err)
+
+			request.Header.Set("Date", tc.date)
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "date %s", tc.date)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with request template matcher", func(t *testing.T) {
+		template, err := http.NewRequest(http.MethodPost, "/books?draft=true", strings.NewReader(`{"title": "Foundation"}`))
+		require.NoError(t, err)
+
+		template.Header.Set("Content-Type", "application/json")
+
+		testCases := []struct {
+			url    string
+			body   string
+			fields []MatchField
+			failed bool
+		}{
+			{url: "/books?draft=true", body: `{ "title":"Foundation" }`, failed: false},
+			{url: "/books?draft=false", body: `{"title": "Foundation"}`, failed: true},
+			{url: "/books?draft=true", body: `{"title": "Dune"}`, failed: true},
+			{url: "/books?draft=false", body: `{"title": "Dune"}`, fields: []MatchField{MethodField, PathField}, failed: false},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/books", MatchLikeRequest(template, tc.fields...)).Respond(ResponseStatusCode(http.StatusCreated))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+tc.url, "application/json", strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "%s %s", tc.url, tc.body)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with exact headers matcher", func(t *testing.T) {
+		testCases := []struct {
+			headers  http.Header
+			failures []string
+		}{
+			{headers: http.Header{"Authorization": {"token"}, "X-Request-Id": {"1"}}},
+			{
+				headers:  http.Header{"Authorization": {"token"}},
+				failures: []string{"missing headers: X-Request-Id"},
+			},
+			{
+				headers: http.Header{"Authorization": {"other"}, "X-Request-Id": {"1"}, "X-Debug": {"true"}, "Cookie": {"a=b"}},
+				failures: []string{
+					`header Authorization differs: got ["other"], expected ["token"]`,
+					"unexpected headers: Cookie, X-Debug",
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			request, err := http.NewRequest(http.MethodPost, "/post", strings.NewReader("{}"))
+			require.NoError(t, err)
+
+			request.Header = tc.headers
+			request.Header.Set("Content-Length", "2")
+			request.Header.Set("User-Agent", "Go-http-client/1.1")
+
+			matcher := MatchHeadersExactly(http.Header{"authorization": {"token"}, "X-Request-Id": {"1"}}, "User-Agent")
+
+			require.Equalf(t, strings.Join(tc.failures, "\n"), matcher.Diff(request), "headers %v", tc.headers)
+		}
+	})
+
+	t.Run("mock request with content type param matcher", func(t *testing.T) {
+		testCases := []struct {
+			contentType string
+			failed      bool
+		}{
+			{contentType: "multipart/form-data; boundary=abc123; charset=utf-8", failed: false},
+			{contentType: `multipart/form-data; Boundary="abc123"; CHARSET=utf-8`, failed: false},
+			{contentType: "multipart/form-data; boundary=xyz; charset=utf-8", failed: true},
+			{contentType: "multipart/form-data; boundary=abc123", failed: true},
+		}
+
+		for _, tc := range testCases {
+			mockT := new(testing.T)
+
+			ms := NewMockServer(WithPort(60000))
+
+			ms.Post("/upload", MatchContentTypeParam("boundary", "abc123"), MatchContentTypeParam("Charset", "utf-8")).
+				Respond(ResponseStatusCode(http.StatusNoContent))
+
+			ms.Start(mockT)
+
+			_, err := http.Post(ms.URL()+"/upload", tc.contentType, http.NoBody)
+			require.NoError(t, err)
+
+			require.Equalf(t, tc.failed, mockT.Failed(), "content type %s", tc.contentType)
+
+			ms.Teardown()
+		}
+	})
+
+	t.Run("mock request with content type in list matcher", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/post", MatchContentTypeIn("application/json", "application/vnd.api+json")).
+			Times(3).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		_, err := http.Post(ms.URL()+"/post", "application/json; charset=utf-8", strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		_, err = http.Post(ms.URL()+"/post", "application/vnd.api+json", strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		require.False(t, mockT.Failed())
+
+		_, err = http.Post(ms.URL()+"/post", "text/plain", strings.NewReader("{}"))
+		require.NoError(t, err)
+
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("mock request with pretty json response", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000), WithPrettyJSON())
+
+		ms.Get("/json").Respond(JSONResponseBody(`{"result":true}`))
+		ms.Get("/text").Respond(StringResponseBody(`{"result":true}`))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		expected := map[string]string{
+			"/json": "{\n  \"result\": true\n}",
+			"/text": `{"result":true}`,
+		}
+
+		for path, body := range expected {
+			response, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+
+			actual, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, body, string(actual))
+		}
+	})
+
+	t.Run("mock request with local port matcher", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get", MatchLocalPort(60000)).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("wait for asynchronous calls before asserting", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Times(3).Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		go func() {
+			for i := 0; i < 3; i++ {
+				time.Sleep(50 * time.Millisecond)
+				_, _ = http.Get(ms.URL() + "/get")
+			}
+		}()
+
+		ms.WaitAndAssert(t, 2*time.Second)
+	})
+
+	t.Run("do not verify expectations again after waiting", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		ms.WaitAndAssert(t, time.Second)
+
+		// the extra call would fail the expectations verified by the cleanup.
+		_, err = http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+	})
+
+	t.Run("mock request with json predicate", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/post", MatchHeader(Headers("X-App", "foo"))).
+			Times(2).
+			ExpectJSON(func(decoded map[string]any) error {
+				if decoded["title"] != "Foundation" {
+					return fmt.Errorf("unexpected title %v", decoded["title"])
+				}
+				return nil
+			}).
+			Respond(ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		for _, title := range []string{"Foundation", "Dune"} {
+			body := strings.NewReader(fmt.Sprintf(`{"title": %q}`, title))
+			request, err := http.NewRequest(http.MethodPost, ms.URL()+"/post", body)
+			require.NoError(t, err)
+
+			request.Header.Set("X-App", "foo")
+
+			_, err = http.DefaultClient.Do(request)
+			require.NoError(t, err)
+
+			require.Equal(t, title == "Dune", mockT.Failed())
+		}
+	})
+
+	t.Run("respond unmapped route with custom not found response", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(
+			WithPort(60000),
+			WithNotFoundResponse(http.StatusNotFound, []byte(`{"error": "not found"}`), "application/json"),
+		)
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/foo")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.JSONEq(t, `{"error": "not found"}`, string(body))
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("respond unmapped route with custom not found response without status", func(t *testing.T) {
+		mockT := new(testing.T)
+
+		ms := NewMockServer(WithPort(60000), WithNotFoundResponse(0, []byte("missing"), "text/plain"))
+
+		ms.Start(mockT)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/foo")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, "missing", string(body))
+		require.True(t, mockT.Failed())
+	})
+
+	t.Run("reset scenario count between phases", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		first := ms.Get("/get").Respond(ResponseStatusCode(http.StatusAccepted))
+		second := ms.Get("/get").Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for _, code := range []int{http.StatusAccepted, http.StatusOK} {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, code, response.StatusCode)
+		}
+
+		second.ResetCount()
+		require.Equal(t, 0, second.TimesCalled())
+		require.Equal(t, 1, first.TimesCalled())
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, 1, second.TimesCalled())
+	})
+
+	t.Run("serve only the reset scenario again", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		first := ms.Get("/get").Respond(ResponseStatusCode(http.StatusAccepted))
+		second := ms.Get("/get").Respond(ResponseStatusCode(http.StatusOK))
+		third := ms.Get("/get").Respond(ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for _, code := range []int{http.StatusAccepted, http.StatusOK} {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, code, response.StatusCode)
+		}
+
+		first.ResetCount()
+
+		for _, code := range []int{http.StatusAccepted, http.StatusCreated} {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			require.Equal(t, code, response.StatusCode)
+		}
+
+		require.Equal(t, 1, first.TimesCalled())
+		require.Equal(t, 1, second.TimesCalled())
+		require.Equal(t, 1, third.TimesCalled())
+	})
+
+	t.Run("trace handled requests", func(t *testing.T) {
+		tracer := &fakeTracer{}
+
+		ms := NewMockServer(WithPort(60000), WithTracing(tracer))
+
+		ms.Get("/books/{id}").Respond(ResponseStatusCode(http.StatusNoContent))
+		ms.Get("/books/{id}").Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		_, err := http.Get(ms.URL() + "/books/1")
+		require.NoError(t, err)
+
+		_, err = http.Get(ms.URL() + "/books/2")
+		require.NoError(t, err)
+
+		require.Len(t, tracer.spans, 2)
+
+		span := tracer.spans[0]
+		require.Equal(t, "GET /books/1", span.name)
+		require.True(t, span.ended)
+		require.Equal(t, map[string]any{
+			AttributeHTTPMethod:     http.MethodGet,
+			AttributeURLPath:        "/books/1",
+			AttributeEndpoint:       "GET /books/{id}",
+			AttributeScenario:       0,
+			AttributeHTTPStatusCode: http.StatusNoContent,
+		}, span.attributes)
+
+		span = tracer.spans[1]
+		require.Equal(t, "GET /books/2", span.name)
+		require.Equal(t, 1, span.attributes[AttributeScenario])
+		require.Equal(t, http.StatusOK, span.attributes[AttributeHTTPStatusCode])
+	})
+
+	t.Run("mock request with custom status reason phrase", func(t *testing.T) {
+		dump := new(bytes.Buffer)
+		ms := NewMockServer(WithPort(60000), WithDumpTo(dump))
+
+		ms.Get("/get").Respond(
+			ResponseStatus(420, "Enhance Your Calm"),
+			StringResponseBody("slow down"),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Get(ms.URL() + "/get")
+		require.NoError(t, err)
+
+		require.Equal(t, 420, response.StatusCode)
+		require.Equal(t, "420 Enhance Your Calm", response.Status)
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, "slow down", string(body))
+
+		// waits for the record of the in-flight request
+		ms.Teardown()
+
+		received := ms.ReceivedRequests()
+		require.Len(t, received, 1)
+		require.Equal(t, 420, received[0].StatusCode)
+
+		transcript := dump.String()
+		require.Contains(t, transcript, "HTTP/1.1 420 Enhance Your Calm\r\n")
+		require.Contains(t, transcript, "slow down")
+	})
+
+	t.Run("handle concurrent requests under load", func(t *testing.T) {
+		ms := NewMockServer()
+
+		const (
+			workers  = 20
+			requests = 25
+		)
+
+		first := ms.Get("/get").Times(workers * requests / 2).Respond(ResponseStatusCode(http.StatusAccepted))
+		second := ms.Get("/get").Times(workers * requests / 2).Respond(ResponseStatusCode(http.StatusOK))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < requests; j++ {
+					response, err := http.Get(ms.URL() + "/get")
+					if !assert.NoError(t, err) {
+						return
+					}
+					_ = response.Body.Close()
+				}
+			}()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		// asserting while requests are in flight must not race with the handlers.
+		for asserting := true; asserting; {
+			select {
+			case <-done:
+				asserting = false
+			default:
+				ms.assertExpectations(new(testing.T))
+			}
+		}
+
+		require.Equal(t, workers*requests/2, first.TimesCalled())
+		require.Equal(t, workers*requests/2, second.TimesCalled())
+		require.Equal(t, workers*requests, ms.TotalRequests())
+		require.GreaterOrEqual(t, ms.ConcurrentCalls(), 1)
+	})
+
+	t.Run("mock request with trailer matcher", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Put("/upload", MatchRequestTrailer("X-Checksum", "abc123")).
+			Respond(ResponseStatusCode(http.StatusNoContent))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		request, err := http.NewRequest(http.MethodPut, ms.URL()+"/upload", io.NopCloser(strings.NewReader("content")))
+		require.NoError(t, err)
+
+		request.TransferEncoding = []string{"chunked"}
+		request.Trailer = http.Header{"X-Checksum": []string{"abc123"}}
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("find received requests by endpoint", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Get("/books/{id}").Times(2).Respond(ResponseStatusCode(http.StatusOK))
+		ms.Post("/books").Respond(ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		for _, path := range []string{"/books/1", "/books/2"} {
+			_, err := http.Get(ms.URL() + path)
+			require.NoError(t, err)
+		}
+
+		_, err := http.Post(ms.URL()+"/books", "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+
+		require.Len(t, ms.FindRequests(http.MethodGet, "/books/{id}"), 2)
+		require.Len(t, ms.FindRequests(http.MethodGet, "/books/2"), 1)
+
+		last, found := ms.LastRequest(http.MethodGet, "/books/{id}")
+		require.True(t, found)
+		require.Equal(t, "/books/2", last.URL.Path)
+
+		_, found = ms.LastRequest(http.MethodDelete, "/books/{id}")
+		require.False(t, found)
+	})
+
+	t.Run("mock request with response spec", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		ms.Post("/post").Respond(
+			ResponseFromSpec(t, `201; Content-Type: application/json; X-Foo: bar; {"id": 1, "note": "a; b"}`),
+		)
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		response, err := http.Post(ms.URL()+"/post", "application/json", nil)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+		require.Equal(t, "application/json", response.Header.Get("Content-Type"))
+		require.Equal(t, "bar", response.Header.Get("X-Foo"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		require.JSONEq(t, `{"id": 1, "note": "a; b"}`, string(body))
+	})
+
+	t.Run("hand over calls after scenario expires", func(t *testing.T) {
+		ms := NewMockServer(WithPort(60000))
+
+		cached := ms.Get("/get").Times(5).Expire(3).Respond(StringResponseBody("cached"))
+		fresh := ms.Get("/get").Times(2).Respond(StringResponseBody("fresh"))
+		last := ms.Get("/get").Respond(StringResponseBody("last"))
+
+		ms.Start(t)
+		defer ms.Teardown()
+
+		expected := []string{"cached", "cached", "cached", "fresh", "fresh", "fresh", "fresh", "last"}
+		for i, content := range expected {
+			response, err := http.Get(ms.URL() + "/get")
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			require.Equalf(t, content, string(body), "request %d was wrong", i)
+		}
+
+		require.Equal(t, 3, cached.TimesCalled())
+		require.Equal(t, 4, fresh.TimesCalled())
+		require.Equal(t, 1, last.TimesCalled())
+
+		mockT := new(testing.T)
+		ms.assertExpectations(mockT)
+		require.False(t, mockT.Failed())
+	})
+}
+
+// This uses the built-in cleanup to perform
+// a integration test similar to what the lib user
+// should write.
+func TestMockServer_Cleanup(t *testing.T) {
+	ms := NewMockServer()
+
+	ms.Get("/get").Respond(ResponseStatusCode(http.StatusNoContent))
+
+	ms.Start(t)
+
+	var response *http.Response
+	require.Eventually(t, func() bool {
+		r, err := http.Get(ms.URL() + "/get")
+		if err != nil {
+			return false
+		}
+		response = r
+		return true
+	}, 2*time.Second, 200*time.Millisecond)
+
+	require.Equal(t, http.StatusNoContent, response.StatusCode)
+}
+
+// failureCounter counts the errors reported to a testing.TB.
+type failureCounter struct {
+	testing.TB
+	failures int
+	messages []string
+}
+
+func (f *failureCounter) Errorf(format string, args ...any) {
+	f.failures++
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+	f.TB.Errorf(format, args...)
+}
+
+// fakeTracer records the spans started by the MockServer.
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (f *fakeTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	span := &fakeSpan{name: spanName, attributes: make(map[string]any)}
+	f.spans = append(f.spans, span)
+
+	return ctx, span
+}
+
+type fakeSpan struct {
+	name       string
+	attributes map[string]any
+	ended      bool
+}
+
+func (f *fakeSpan) SetAttribute(key string, value any) {
+	f.attributes[key] = value
+}
+
+func (f *fakeSpan) End() {
+	f.ended = true
 }
@@ -0,0 +1,63 @@
+package mockhttp
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts spans for the requests handled by the MockServer.
+//
+// It mirrors the shape of OpenTelemetry tracers, so an adapter to any
+// tracing library is a few lines long.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a traced operation started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value any)
+	End()
+}
+
+// Span attributes set for each handled request.
+const (
+	AttributeHTTPMethod     = "http.request.method"
+	AttributeURLPath        = "url.path"
+	AttributeEndpoint       = "mockhttp.endpoint"
+	AttributeScenario       = "mockhttp.scenario"
+	AttributeHTTPStatusCode = "http.response.status_code"
+)
+
+// WithTracing makes the MockServer start a span for each request, with attributes
+// for its method, path, matched endpoint, the zero-based index of the scenario that
+// responded in the endpoint registration order, and response status.
+func WithTracing(tracer Tracer) Option {
+	return func(ms *MockServer) {
+		ms.tracer = tracer
+	}
+}
+
+// trace is a middleware that wraps each request in a span of the configured tracer.
+func (ms *MockServer) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := ms.tracer.Start(r.Context(), r.Method+" "+r.URL.Path)
+		defer span.End()
+
+		span.SetAttribute(AttributeHTTPMethod, r.Method)
+		span.SetAttribute(AttributeURLPath, r.URL.Path)
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r.WithContext(ctx))
+
+		if sr.statusCode == 0 {
+			sr.statusCode = http.StatusOK
+		}
+
+		if handled, ok := r.Context().Value(handledByKey{}).(*handledBy); ok && handled.endpoint != "" {
+			span.SetAttribute(AttributeEndpoint, handled.endpoint)
+			span.SetAttribute(AttributeScenario, handled.scenario)
+		}
+
+		span.SetAttribute(AttributeHTTPStatusCode, sr.statusCode)
+	})
+}
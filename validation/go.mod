@@ -0,0 +1,28 @@
+module github.com/caiorcferreira/mockhttp/validation
+
+go 1.20
+
+require (
+	github.com/caiorcferreira/mockhttp v0.0.0
+	github.com/go-playground/validator/v10 v10.15.5
+	github.com/stretchr/testify v1.8.2
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gabriel-vasile/mimetype v1.4.2 // indirect
+	github.com/go-chi/chi/v5 v5.0.4 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/leodido/go-urn v1.2.4 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.7.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/text v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/caiorcferreira/mockhttp => ../
@@ -0,0 +1,46 @@
+// Package validation provides mockhttp matchers backed by go-playground/validator,
+// kept apart so the main package does not depend on it.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/caiorcferreira/mockhttp"
+	"github.com/go-playground/validator/v10"
+)
+
+// MatchStructValid verifies that the request body unmarshals into a value of the same
+// type as target and that the value passes the rules of its validate struct tags.
+func MatchStructValid(target any) mockhttp.Matcher {
+	targetType := reflect.TypeOf(target)
+	for targetType.Kind() == reflect.Pointer {
+		targetType = targetType.Elem()
+	}
+
+	validate := validator.New()
+
+	description := fmt.Sprintf("validation.MatchStructValid(%s)", targetType)
+
+	return mockhttp.NewDescribedMatcher(description, func(t testing.TB, r *http.Request) {
+		t.Helper()
+		body, err := mockhttp.ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		value := reflect.New(targetType).Interface()
+		if err := json.Unmarshal(body, value); err != nil {
+			t.Errorf("body does not unmarshal into %s: %s", targetType, err.Error())
+			return
+		}
+
+		if err := validate.Struct(value); err != nil {
+			t.Errorf("body is not a valid %s: %s", targetType, err.Error())
+		}
+	})
+}
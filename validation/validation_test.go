@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/caiorcferreira/mockhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStructValid(t *testing.T) {
+	type signup struct {
+		Email string `json:"email" validate:"required,email"`
+		Age   int    `json:"age" validate:"min=18"`
+	}
+
+	testCases := []struct {
+		body   string
+		failed bool
+	}{
+		{body: `{"email": "ann@example.com", "age": 30}`, failed: false},
+		{body: `{"email": "ann", "age": 30}`, failed: true},
+		{body: `{"age": 30}`, failed: true},
+		{body: `{"email": "ann@example.com", "age": 12}`, failed: true},
+		{body: `{"email": 1}`, failed: true},
+	}
+
+	for _, tc := range testCases {
+		mockT := new(testing.T)
+
+		ms := mockhttp.NewMockServer()
+
+		ms.Post("/signup", MatchStructValid(signup{})).Respond(mockhttp.ResponseStatusCode(http.StatusCreated))
+
+		ms.Start(mockT)
+
+		response, err := http.Post(ms.URL()+"/signup", "application/json", strings.NewReader(tc.body))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, response.StatusCode)
+		require.Equalf(t, tc.failed, mockT.Failed(), "body %s", tc.body)
+
+		ms.Teardown()
+	}
+}
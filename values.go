@@ -0,0 +1,42 @@
+package mockhttp
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Query builds url.Values from key-value pairs, e.g. Query("page", "1", "tag", "go").
+// Repeated keys accumulate their values.
+//
+// It panics if an odd number of arguments is given.
+func Query(kv ...string) url.Values {
+	mustBePairs("Query", kv)
+
+	values := make(url.Values)
+	for i := 0; i < len(kv); i += 2 {
+		values.Add(kv[i], kv[i+1])
+	}
+
+	return values
+}
+
+// Headers builds an http.Header from name-value pairs, e.g. Headers("Accept", "application/json").
+// Names are canonicalized and repeated names accumulate their values.
+//
+// It panics if an odd number of arguments is given.
+func Headers(kv ...string) http.Header {
+	mustBePairs("Headers", kv)
+
+	headers := make(http.Header)
+	for i := 0; i < len(kv); i += 2 {
+		headers.Add(kv[i], kv[i+1])
+	}
+
+	return headers
+}
+
+func mustBePairs(fn string, kv []string) {
+	if len(kv)%2 != 0 {
+		panic("mockhttp: " + fn + " expects key-value pairs, got an odd number of arguments")
+	}
+}
@@ -0,0 +1,103 @@
+package mockhttp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// MatchXMLBody verifies that the request body is an XML document equivalent to expected,
+// ignoring whitespace between elements, attribute order, comments and processing instructions.
+func MatchXMLBody(expected string) Matcher {
+	return NewDescribedMatcher(describeCall("MatchXMLBody", expected), func(t testing.TB, r *http.Request) {
+		t.Helper()
+		want, err := parseXML([]byte(expected))
+		if err != nil {
+			t.Errorf("invalid expected xml: %s", err.Error())
+			return
+		}
+
+		body, err := ReadBody(r)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		got, err := parseXML(body)
+		if err != nil {
+			t.Errorf("invalid xml body: %s", err.Error())
+			return
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("xml body differs (-expected +actual):\n%s", diff)
+		}
+	})
+}
+
+// xmlNode is an XML element normalized for comparison.
+type xmlNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+	Children []*xmlNode
+}
+
+// parseXML parses the document into its normalized root element.
+func parseXML(doc []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			attrs := append([]xml.Attr(nil), tok.Attr...)
+			sort.Slice(attrs, func(i, j int) bool {
+				if attrs[i].Name.Space != attrs[j].Name.Space {
+					return attrs[i].Name.Space < attrs[j].Name.Space
+				}
+
+				return attrs[i].Name.Local < attrs[j].Name.Local
+			})
+
+			node := &xmlNode{Name: tok.Name, Attrs: attrs}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			}
+
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += strings.TrimSpace(string(tok))
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("document has no root element")
+	}
+
+	return root, nil
+}